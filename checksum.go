@@ -0,0 +1,65 @@
+package sie
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"unicode"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ErrChecksumMissing is returned by Parse when the file starts a #KSUMMA
+// checksum but doesn't end with one, which usually means it was truncated.
+var ErrChecksumMissing = errors.New("file has a #KSUMMA header but no checksum")
+
+// ChecksumError is returned by Parse when the #KSUMMA checksum in the file
+// doesn't match the contents.
+type ChecksumError struct {
+	Expected uint32 // as given in the file
+	Actual   uint32 // as calculated from the contents
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch: file says %d, contents give %d", e.Expected, e.Actual)
+}
+
+// checksum is the CRC-32 used by #KSUMMA. It covers the label and field
+// contents of each record in PC8 encoding; the whitespace between fields,
+// the quotes and braces around fields, and line breaks are not included.
+type checksum struct {
+	hash.Hash32
+}
+
+func newChecksum() *checksum {
+	return &checksum{crc32.NewIEEE()}
+}
+
+func (c *checksum) addRecord(line string) {
+	buf := make([]byte, 0, len(line))
+	inQuote, inEscape := false, false
+	for _, r := range line {
+		switch {
+		case inEscape:
+			inEscape = false
+		case r == '\\':
+			inEscape = true
+			continue
+		case r == '\r' || r == '\n':
+			// Line breaks are never included, even within quotes
+			continue
+		case r == '"':
+			inQuote = !inQuote
+			continue
+		case !inQuote && (r == '{' || r == '}' || unicode.IsSpace(r)):
+			continue
+		}
+		b, ok := charmap.CodePage437.EncodeRune(r)
+		if !ok {
+			b = '?'
+		}
+		buf = append(buf, b)
+	}
+	_, _ = c.Write(buf)
+}
@@ -0,0 +1,73 @@
+package sie
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"os"
+	"testing"
+)
+
+func TestChecksumRecord(t *testing.T) {
+	cases := []struct {
+		in  string
+		out string
+	}{
+		{`#KONTO 1930 "Bank konto"`, "#KONTO1930Bank konto"},
+		{`#TRANS 1930 {6 "P 1"} 100.00`, "#TRANS19306P 1100.00"},
+		{`#FNAMN "Citat \"AB\""`, `#FNAMNCitat "AB"`},
+		{"#FNAMN \"Räksmörgås\"\r", "#FNAMNR\x84ksm\x94rg\x86s"},
+	}
+
+	for _, tc := range cases {
+		sum := newChecksum()
+		sum.addRecord(tc.in)
+		if got, exp := sum.Sum32(), crc32.ChecksumIEEE([]byte(tc.out)); got != exp {
+			t.Errorf("checksum of %q is %d, expected %d (for %q)", tc.in, got, exp, tc.out)
+		}
+	}
+}
+
+func TestChecksumRoundtrip(t *testing.T) {
+	fd, err := os.Open("testdata/testdata.se")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	doc, err := Parse(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteWithOptions(&buf, doc, WriteOptions{Checksum: true}); err != nil {
+		t.Fatal(err)
+	}
+	written := buf.Bytes()
+	if !bytes.Contains(written, []byte("#FLAGGA 0\r\n#KSUMMA\r\n")) {
+		t.Error("missing #KSUMMA header")
+	}
+
+	if _, err := Parse(bytes.NewReader(written)); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// An edited file fails verification, unless verification is turned off
+
+	edited := bytes.Replace(written, []byte("48043.00"), []byte("48044.00"), 1)
+	_, err = Parse(bytes.NewReader(edited))
+	var csErr *ChecksumError
+	if !errors.As(err, &csErr) {
+		t.Fatal("expected checksum error, got", err)
+	}
+	if _, err := ParseWithOptions(bytes.NewReader(edited), ParseOptions{IgnoreChecksum: true}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// A truncated file is missing the final checksum
+
+	truncated := written[:bytes.LastIndex(written, []byte("#KSUMMA"))]
+	if _, err := Parse(bytes.NewReader(truncated)); !errors.Is(err, ErrChecksumMissing) {
+		t.Fatal("expected missing checksum error, got", err)
+	}
+}
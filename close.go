@@ -0,0 +1,216 @@
+package sie
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Accounts in the BAS plan used when closing the year
+const (
+	basResultAccount     = 2099 // Årets resultat, balance sheet
+	basYearResultAccount = 8999 // Årets resultat, income statement
+)
+
+// ErrNoFiscalYear is returned by CloseYear when the document doesn't
+// declare the current fiscal year with #RAR 0.
+var ErrNoFiscalYear = errors.New("document has no fiscal year")
+
+type CloseOptions struct {
+	// Series and ID of the closing entry. They may be left empty for the
+	// receiving system to assign.
+	Series string
+	ID     string
+
+	// Text is the description of the closing entry. Defaults to
+	// "Bokslut".
+	Text string
+
+	// ResultAccount is the equity account the result is transferred to.
+	// Defaults to 2099, Årets resultat; sole traders use 2019.
+	ResultAccount int
+
+	// NextEnds is the last day of the next fiscal year. Defaults to twelve
+	// months after the end of the closed year. A shortened or extended
+	// year, such as when changing the fiscal year, must be between 6 and
+	// 18 months long, the limits of bokföringslagen.
+	NextEnds time.Time
+}
+
+// Closing is the outcome of closing a fiscal year.
+type Closing struct {
+	// Result is the result of the year, positive for a profit.
+	Result Decimal
+
+	// Entry is the closing entry, dated the last day of the year. It
+	// resets the result accounts and transfers their balance to the result
+	// account.
+	Entry Entry
+
+	// Next is the document for the next fiscal year, without entries. The
+	// opening balances are the closing balances after the closing entry,
+	// and the balances of the closed year move to Previous.
+	Next *Document
+}
+
+// CloseYear closes the current fiscal year, based on the closing balances
+// and results in the document (#UB and #RES). The document itself is left
+// unchanged; the closing entry may be added to it or to the accounting
+// system the file came from. The year must be declared by #RAR, and all
+// accounts must be balance or result accounts, by #KTYP or by BAS.
+//
+// Some systems book the result on 8999, Årets resultat, against 2099
+// before closing. That account is reset like the other result accounts but
+// doesn't count towards the result.
+func (d *Document) CloseYear(opts CloseOptions) (*Closing, error) {
+	year, ok := d.yearDeclared(0)
+	if !ok {
+		return nil, ErrNoFiscalYear
+	}
+	if opts.Text == "" {
+		opts.Text = "Bokslut"
+	}
+	if opts.ResultAccount == 0 {
+		opts.ResultAccount = basResultAccount
+	}
+	nextStarts := year.Ends.AddDate(0, 0, 1)
+	if opts.NextEnds.IsZero() {
+		opts.NextEnds = nextStarts.AddDate(1, 0, -1)
+	}
+	if n := months(nextStarts, opts.NextEnds); n < 6 || n > 18 {
+		return nil, fmt.Errorf("next fiscal year %s - %s is not between 6 and 18 months", nextStarts.Format("2006-01-02"), opts.NextEnds.Format("2006-01-02"))
+	}
+
+	accounts := d.Accounts
+	i := slices.IndexFunc(accounts, func(acc Account) bool { return acc.ID == opts.ResultAccount })
+	switch {
+	case i == -1:
+		acc := Account{ID: opts.ResultAccount, Type: Liability}
+		if pa, ok := BAS().Account(opts.ResultAccount); ok {
+			acc.Description = pa.Name
+		}
+		accounts = append(slices.Clip(accounts), acc)
+		slices.SortFunc(accounts, func(a, b Account) int { return cmp.Compare(a.ID, b.ID) })
+	case !accounts[i].IsBalanceAccount(d.AccountPlan):
+		return nil, fmt.Errorf("result account %d is not a balance account", opts.ResultAccount)
+	}
+	for _, acc := range accounts {
+		if !acc.IsBalanceAccount(d.AccountPlan) && !acc.IsResultAccount(d.AccountPlan) {
+			return nil, fmt.Errorf("account %d is neither a balance nor a result account", acc.ID)
+		}
+	}
+
+	c := &Closing{
+		Entry: Entry{
+			ID:          opts.ID,
+			Type:        opts.Series,
+			Date:        year.Ends,
+			Description: opts.Text,
+		},
+	}
+	var transfer Decimal
+	for _, acc := range accounts {
+		if !acc.IsResultAccount(d.AccountPlan) {
+			continue
+		}
+		res := closingResult(acc)
+		if res == 0 {
+			continue
+		}
+		c.Entry.Add(acc.ID, -res)
+		transfer += res
+		if acc.ID != basYearResultAccount {
+			c.Result -= res
+		}
+	}
+	if transfer != 0 {
+		c.Entry.Add(opts.ResultAccount, transfer)
+	}
+
+	next := *d
+	next.Entries = nil
+	next.Unknown = nil
+	next.Warnings = nil
+	next.Starts = nextStarts
+	next.Ends = opts.NextEnds
+	next.Years = []FiscalYear{{Index: 0, Starts: next.Starts, Ends: next.Ends}}
+	for _, y := range d.Years {
+		y.Index--
+		next.Years = append(next.Years, y)
+	}
+	next.Accounts = copyAccounts(accounts, func(acc *Account) {
+		closing := acc.OutBalance
+		if acc.ID == opts.ResultAccount {
+			closing += transfer
+		}
+		if acc.IsResultAccount(d.AccountPlan) {
+			closing = 0
+		}
+
+		prev := make(map[int]Balance, len(acc.Previous)+1)
+		for y, b := range acc.Previous {
+			prev[y-1] = b
+		}
+		if b := acc.Balance(0); b != (Balance{}) {
+			prev[-1] = b
+		}
+		acc.Previous = prev
+		if len(prev) == 0 {
+			acc.Previous = nil
+		}
+		acc.InBalance, acc.OutBalance, acc.Result = closing, closing, 0
+
+		obs := make([]ObjectBalance, 0, len(acc.ObjectBalances))
+		for _, ob := range acc.ObjectBalances {
+			if ob.Year == 0 && acc.IsBalanceAccount(d.AccountPlan) {
+				obs = append(obs, ObjectBalance{
+					Annotation:  ob.Annotation,
+					In:          ob.Out,
+					Out:         ob.Out,
+					InQuantity:  ob.OutQuantity,
+					OutQuantity: ob.OutQuantity,
+				})
+			}
+			ob.Year--
+			obs = append(obs, ob)
+		}
+		acc.ObjectBalances = obs
+		if len(obs) == 0 {
+			acc.ObjectBalances = nil
+		}
+		acc.Periods = previousPeriods(acc.Periods)
+		acc.Budgets = previousPeriods(acc.Budgets)
+	})
+	c.Next = &next
+	return c, nil
+}
+
+// closingResult returns the result of a result account, from #RES or, in
+// files that give it as a closing balance, #UB.
+func closingResult(acc Account) Decimal {
+	if acc.Result != 0 {
+		return acc.Result
+	}
+	return acc.OutBalance
+}
+
+// previousPeriods returns the period balances moved back one fiscal year.
+func previousPeriods(pbs []PeriodBalance) []PeriodBalance {
+	if len(pbs) == 0 {
+		return nil
+	}
+	res := make([]PeriodBalance, len(pbs))
+	for i, pb := range pbs {
+		pb.Year--
+		res[i] = pb
+	}
+	return res
+}
+
+// months returns the number of calendar months from the month of starts to
+// that of ends, both included.
+func months(starts, ends time.Time) int {
+	return (ends.Year()-starts.Year())*12 + int(ends.Month()-starts.Month()) + 1
+}
@@ -0,0 +1,186 @@
+package sie
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCloseYear(t *testing.T) {
+	starts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ends := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	p1 := Annotation{Tag: 1, Text: "P1"}
+	doc := &Document{
+		CompanyName: "Test AB",
+		Starts:      starts,
+		Ends:        ends,
+		Years:       []FiscalYear{{Index: 0, Starts: starts, Ends: ends}},
+		Accounts: []Account{
+			{
+				ID: 1930, InBalance: 100000, OutBalance: 150000,
+				ObjectBalances: []ObjectBalance{{Annotation: p1, In: 10000, Out: 20000}},
+			},
+			{ID: 2081, InBalance: -100000, OutBalance: -100000},
+			{ID: 3001, Result: -80000, Periods: []PeriodBalance{{Period: starts, Amount: -80000}}},
+			{ID: 5010, Result: 30000},
+		},
+		Entries: []Entry{{ID: "1", Date: starts}},
+	}
+
+	c, err := doc.CloseYear(CloseOptions{Series: "A", ID: "99"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Result != 50000 {
+		t.Errorf("result %v, expected 500.00", c.Result)
+	}
+
+	expected := Entry{ID: "99", Type: "A", Date: ends, Description: "Bokslut"}
+	expected.Add(3001, 80000).Add(5010, -30000).Add(2099, -50000)
+	if !reflect.DeepEqual(c.Entry, expected) {
+		t.Errorf("unexpected closing entry:\n%+v", c.Entry)
+	}
+
+	next := c.Next
+	nextStarts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	nextEnds := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+	if !next.Starts.Equal(nextStarts) || !next.Ends.Equal(nextEnds) {
+		t.Errorf("next year is %v - %v", next.Starts, next.Ends)
+	}
+	if y, _ := next.Year(-1); !y.Starts.Equal(starts) {
+		t.Errorf("previous year is %+v", y)
+	}
+	if len(next.Entries) != 0 {
+		t.Error("next year has entries")
+	}
+
+	balances := make(map[int]Decimal)
+	for _, acc := range next.Accounts {
+		if acc.InBalance != acc.OutBalance || acc.Result != 0 {
+			t.Errorf("account %d: unexpected balances %+v", acc.ID, acc.Balance(0))
+		}
+		balances[acc.ID] = acc.InBalance
+	}
+	if exp := map[int]Decimal{1930: 150000, 2081: -100000, 2099: -50000, 3001: 0, 5010: 0}; !reflect.DeepEqual(balances, exp) {
+		t.Errorf("unexpected opening balances %v", balances)
+	}
+
+	acc := next.Accounts[0]
+	if b := acc.Balance(-1); b.In != 100000 || b.Out != 150000 {
+		t.Errorf("previous balance of 1930 is %+v", b)
+	}
+	if exp := []ObjectBalance{
+		{Annotation: p1, In: 20000, Out: 20000},
+		{Year: -1, Annotation: p1, In: 10000, Out: 20000},
+	}; !reflect.DeepEqual(acc.ObjectBalances, exp) {
+		t.Errorf("unexpected object balances %+v", acc.ObjectBalances)
+	}
+	if acc := next.Accounts[3]; acc.ID != 3001 || acc.Balance(-1).Result != -80000 || acc.Periods[0].Year != -1 {
+		t.Errorf("unexpected previous year for 3001: %+v", acc)
+	}
+	if acc := next.Accounts[2]; acc.Description != "Årets resultat" || acc.Type != Liability {
+		t.Errorf("unexpected result account %+v", acc)
+	}
+	if problems := next.Validate(); len(problems) != 0 {
+		t.Errorf("next year does not validate: %v", problems)
+	}
+
+	// The original document is untouched
+	if len(doc.Accounts) != 4 || len(doc.Entries) != 1 || doc.Accounts[0].InBalance != 100000 {
+		t.Error("original document was modified")
+	}
+}
+
+func TestCloseYearBookedResult(t *testing.T) {
+	// The result is already booked on 8999 against 2099
+	starts, ends := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	doc := &Document{
+		Starts: starts,
+		Ends:   ends,
+		Years:  []FiscalYear{{Index: 0, Starts: starts, Ends: ends}},
+		Accounts: []Account{
+			{ID: 1930, OutBalance: 50000},
+			{ID: 2099, OutBalance: -50000},
+			{ID: 3001, Result: -50000},
+			{ID: 8999, Result: 50000},
+		},
+	}
+	c, err := doc.CloseYear(CloseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Result != 50000 {
+		t.Errorf("result %v, expected 500.00", c.Result)
+	}
+	if len(c.Entry.Transactions) != 2 {
+		t.Errorf("expected only the result accounts to be reset: %+v", c.Entry.Transactions)
+	}
+	if acc := c.Next.Accounts[1]; acc.InBalance != -50000 {
+		t.Errorf("opening balance of 2099 is %v", acc.InBalance)
+	}
+}
+
+func TestCloseYearNextEnds(t *testing.T) {
+	// Changing the fiscal year to July - June, with an extended or a
+	// shortened year in between
+	starts, ends := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	doc := &Document{
+		Starts:   starts,
+		Ends:     ends,
+		Years:    []FiscalYear{{Index: 0, Starts: starts, Ends: ends}},
+		Accounts: []Account{{ID: 1930, InBalance: 100, OutBalance: 100}},
+	}
+	for _, nextEnds := range []time.Time{
+		time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC), // 18 months
+		time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC), // 6 months
+	} {
+		c, err := doc.CloseYear(CloseOptions{NextEnds: nextEnds})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if y, _ := c.Next.Year(0); !y.Ends.Equal(nextEnds) || !c.Next.Ends.Equal(nextEnds) {
+			t.Errorf("next year is %+v, expected it to end %v", y, nextEnds)
+		}
+	}
+
+	for _, nextEnds := range []time.Time{
+		time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC), // 19 months
+		time.Date(2025, 5, 31, 0, 0, 0, 0, time.UTC), // 5 months
+		time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+	} {
+		if _, err := doc.CloseYear(CloseOptions{NextEnds: nextEnds}); err == nil {
+			t.Errorf("expected an error for a next year ending %v", nextEnds)
+		}
+	}
+}
+
+func TestCloseYearErrors(t *testing.T) {
+	if _, err := (&Document{}).CloseYear(CloseOptions{}); !errors.Is(err, ErrNoFiscalYear) {
+		t.Errorf("expected ErrNoFiscalYear, got %v", err)
+	}
+
+	// Starts and Ends alone don't declare the year
+	starts, ends := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	doc := &Document{
+		Starts:   starts,
+		Ends:     ends,
+		Accounts: []Account{{ID: 3001, Result: -100}},
+	}
+	if _, err := doc.CloseYear(CloseOptions{}); !errors.Is(err, ErrNoFiscalYear) {
+		t.Errorf("expected ErrNoFiscalYear without #RAR, got %v", err)
+	}
+
+	doc.Years = []FiscalYear{{Index: 0, Starts: starts, Ends: ends}}
+	if _, err := doc.CloseYear(CloseOptions{ResultAccount: 3001}); err == nil {
+		t.Error("expected an error for a result account that is not a balance account")
+	}
+
+	doc.AccountPlan = "K1"
+	doc.Accounts = []Account{{ID: 1930, Type: Asset, OutBalance: 100}, {ID: 4010, OutBalance: -100}}
+	if _, err := doc.CloseYear(CloseOptions{}); err == nil || !strings.Contains(err.Error(), "account 4010") {
+		t.Errorf("expected an error for the untyped account, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"log/slog"
 	"os"
 
@@ -9,13 +10,37 @@ import (
 )
 
 func main() {
-	doc, err := sie.Parse(os.Stdin)
+	layoutFile := flag.String("layout", "", "Account layout file (JSON), default BAS")
+	recalculate := flag.Bool("recalculate", false, "Compute balances from the entries in the file")
+	flag.Parse()
+
+	layout := excel.BASLayout()
+	if *layoutFile != "" {
+		fd, err := os.Open(*layoutFile)
+		if err != nil {
+			slog.Error("Error opening layout", "error", err)
+			os.Exit(1)
+		}
+		layout, err = excel.LoadLayout(fd)
+		fd.Close()
+		if err != nil {
+			slog.Error("Error reading layout", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	doc, err := sie.ParseAny(os.Stdin)
 	if err != nil {
 		slog.Error("Error parsing SIE file", "error", err)
 		os.Exit(1)
 	}
+	if *recalculate {
+		for _, p := range doc.Recalculate() {
+			slog.Warn("Balance differs from entries", "account", p.AccountID, "problem", p.Message)
+		}
+	}
 
-	bs, err := excel.ResultXLSX(doc)
+	bs, err := excel.ResultXLSXWithLayout(doc, layout)
 	if err != nil {
 		slog.Error("Error creating Excel file", "error", err)
 		os.Exit(1)
@@ -25,7 +50,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	bs, err = excel.BalanceXLSX(doc)
+	bs, err = excel.BalanceXLSXWithLayout(doc, layout)
 	if err != nil {
 		slog.Error("Error creating Excel file", "error", err)
 		os.Exit(1)
@@ -34,4 +59,26 @@ func main() {
 		slog.Error("Error writing Excel file", "error", err)
 		os.Exit(1)
 	}
+
+	if !hasBudget(doc) {
+		return
+	}
+	bs, err = excel.BudgetXLSXWithLayout(doc, layout)
+	if err != nil {
+		slog.Error("Error creating Excel file", "error", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile("budget.xlsx", bs, 0o644); err != nil {
+		slog.Error("Error writing Excel file", "error", err)
+		os.Exit(1)
+	}
+}
+
+func hasBudget(doc *sie.Document) bool {
+	for _, acc := range doc.Accounts {
+		if len(acc.Budgets) > 0 {
+			return true
+		}
+	}
+	return false
 }
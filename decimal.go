@@ -0,0 +1,192 @@
+package sie
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// RoundingMode decides how results that fall between two öre, or
+// between two values at the requested number of decimals, are rounded.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds halves away from zero, as is customary in
+	// bookkeeping.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds halves to the nearest even value (banker's
+	// rounding), avoiding a bias when summing many rounded values.
+	RoundHalfEven
+	// RoundDown truncates towards zero.
+	RoundDown
+)
+
+// Add returns d + o, or ErrOverflow if the sum doesn't fit.
+func (d Decimal) Add(o Decimal) (Decimal, error) {
+	s := d + o
+	if (s > d) != (o > 0) {
+		return 0, ErrOverflow
+	}
+	return s, nil
+}
+
+// Sub returns d - o, or ErrOverflow if the difference doesn't fit.
+func (d Decimal) Sub(o Decimal) (Decimal, error) {
+	s := d - o
+	if (s < d) != (o > 0) {
+		return 0, ErrOverflow
+	}
+	return s, nil
+}
+
+func (d Decimal) Neg() Decimal {
+	return -d
+}
+
+func (d Decimal) Abs() Decimal {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// Cmp returns -1, 0 or +1 depending on whether d is less than, equal to or
+// greater than o.
+func (d Decimal) Cmp(o Decimal) int {
+	switch {
+	case d < o:
+		return -1
+	case d > o:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d.
+func (d Decimal) Sign() int {
+	return d.Cmp(0)
+}
+
+// Mul returns d multiplied by q, such as a price times a quantity, rounded
+// to öre.
+func (d Decimal) Mul(q Quantity, mode RoundingMode) (Decimal, error) {
+	num := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(q.unscaled))
+	return decimalFromBig(quoRound(num, pow10(int(q.scale)), mode))
+}
+
+// Div returns d divided by q, such as the price per unit, rounded to öre.
+func (d Decimal) Div(q Quantity, mode RoundingMode) (Decimal, error) {
+	if q.IsZero() {
+		return 0, errors.New("sie: division by zero")
+	}
+	num := new(big.Int).Mul(big.NewInt(int64(d)), pow10(int(q.scale)))
+	return decimalFromBig(quoRound(num, big.NewInt(q.unscaled), mode))
+}
+
+// Percent returns p percent of d, rounded to öre. For example, 25 percent
+// VAT on 100.00 is Decimal(10000).Percent(NewQuantity(25, 0), RoundHalfUp).
+func (d Decimal) Percent(p Quantity, mode RoundingMode) (Decimal, error) {
+	num := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(p.unscaled))
+	return decimalFromBig(quoRound(num, pow10(int(p.scale)+2), mode))
+}
+
+// Round returns d rounded to the given number of decimals, such as zero
+// for whole kronor or -3 for thousands. Amounts are in öre, so two or more
+// decimals leave d unchanged; use Quantity for values with more decimals.
+func (d Decimal) Round(decimals int, mode RoundingMode) (Decimal, error) {
+	if decimals >= 2 {
+		return d, nil
+	}
+	unit := pow10(2 - decimals)
+	n := quoRound(big.NewInt(int64(d)), unit, mode)
+	return decimalFromBig(n.Mul(n, unit))
+}
+
+// quoRound returns num/den rounded according to mode.
+func quoRound(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() != 0 && mode != RoundDown {
+		// Compare twice the remainder to the divisor to see if we're
+		// past, at or before the half
+		half := new(big.Int).Abs(r)
+		half.Lsh(half, 1).Sub(half, new(big.Int).Abs(den))
+		if half.Sign() > 0 || half.Sign() == 0 && (mode == RoundHalfUp || q.Bit(0) == 1) {
+			q.Add(q, big.NewInt(int64(num.Sign()*den.Sign())))
+		}
+	}
+	return q
+}
+
+// decimalFromBig returns the amount n in öre, or ErrOverflow if it
+// doesn't fit.
+func decimalFromBig(n *big.Int) (Decimal, error) {
+	if !n.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return Decimal(n.Int64()), nil
+}
+
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalText(b []byte) error {
+	v, err := ParseDecimal(strings.TrimSpace(string(b)))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Value implements driver.Valuer. The amount is given as a decimal string,
+// which databases convert to NUMERIC columns without loss.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.FloatString(2), nil
+}
+
+// Scan implements sql.Scanner. It reads back the decimal string written by
+// Value, as returned by drivers for NUMERIC and text columns. Values with
+// more than two decimals are an error rather than rounded. Integers, as
+// returned by some drivers for whole numbers, are whole kronor. Floats are
+// an error, as they can't hold all amounts in öre exactly; such columns
+// should be NUMERIC or text instead.
+func (d *Decimal) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*d = 0
+		return nil
+	case int64:
+		if v > math.MaxInt64/100 || v < math.MinInt64/100 {
+			return fmt.Errorf("unable to scan %d into Decimal: %w", v, ErrOverflow)
+		}
+		*d = Decimal(v * 100)
+		return nil
+	case float64:
+		return fmt.Errorf("unable to scan %v into Decimal: floating point values are not exact", v)
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("unable to scan %T into Decimal", src)
+	}
+	q, err := ParseQuantity(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	if q.Scale() > 2 {
+		return fmt.Errorf("unable to scan %q into Decimal: more than two decimals", s)
+	}
+	v, err := q.Decimal(RoundDown)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
@@ -0,0 +1,208 @@
+package sie
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDecimalArithmetic(t *testing.T) {
+	cases := []struct {
+		name string
+		got  Decimal
+		exp  Decimal
+	}{
+		{"neg", Decimal(150).Neg(), -150},
+		{"abs", Decimal(-150).Abs(), 150},
+	}
+	for _, tc := range cases {
+		if tc.got != tc.exp {
+			t.Errorf("%s: got %d, expected %d", tc.name, tc.got, tc.exp)
+		}
+	}
+
+	if d, err := Decimal(150).Add(-275); err != nil || d != -125 {
+		t.Errorf("add: got %d, %v", d, err)
+	}
+	if d, err := Decimal(150).Sub(275); err != nil || d != -125 {
+		t.Errorf("sub: got %d, %v", d, err)
+	}
+	for _, fn := range []func() (Decimal, error){
+		func() (Decimal, error) { return Decimal(math.MaxInt64).Add(1) },
+		func() (Decimal, error) { return Decimal(math.MinInt64).Add(-1) },
+		func() (Decimal, error) { return Decimal(math.MinInt64).Sub(1) },
+		func() (Decimal, error) { return Decimal(0).Sub(math.MinInt64) },
+	} {
+		if _, err := fn(); !errors.Is(err, ErrOverflow) {
+			t.Errorf("expected overflow, got %v", err)
+		}
+	}
+
+	if Decimal(1).Cmp(2) != -1 || Decimal(2).Cmp(2) != 0 || Decimal(3).Cmp(2) != 1 {
+		t.Error("unexpected Cmp result")
+	}
+	if Decimal(-5).Sign() != -1 || Decimal(0).Sign() != 0 {
+		t.Error("unexpected Sign result")
+	}
+}
+
+func TestDecimalRounding(t *testing.T) {
+	half, third := NewQuantity(5, 1), NewQuantity(3, 0)
+	cases := []struct {
+		name string
+		fn   func() (Decimal, error)
+		exp  Decimal
+	}{
+		// 12.34 * 1.5 = 18.51
+		{"mul", func() (Decimal, error) { return Decimal(1234).Mul(NewQuantity(15, 1), RoundHalfUp) }, 1851},
+		// 0.05 * 0.5 = 0.025
+		{"mul half up", func() (Decimal, error) { return Decimal(5).Mul(half, RoundHalfUp) }, 3},
+		{"mul half even", func() (Decimal, error) { return Decimal(5).Mul(half, RoundHalfEven) }, 2},
+		{"mul down", func() (Decimal, error) { return Decimal(5).Mul(half, RoundDown) }, 2},
+		{"mul negative half up", func() (Decimal, error) { return Decimal(-5).Mul(half, RoundHalfUp) }, -3},
+		{"mul negative half even", func() (Decimal, error) { return Decimal(-5).Mul(half, RoundHalfEven) }, -2},
+		// 10.00 * 0.125 = 1.25
+		{"mul three decimals", func() (Decimal, error) { return Decimal(1000).Mul(NewQuantity(125, 3), RoundHalfUp) }, 125},
+
+		// 10.00 / 3 = 3.333...
+		{"div", func() (Decimal, error) { return Decimal(1000).Div(third, RoundHalfUp) }, 333},
+		// 20.00 / 3 = 6.666...
+		{"div up", func() (Decimal, error) { return Decimal(2000).Div(third, RoundHalfUp) }, 667},
+		{"div down", func() (Decimal, error) { return Decimal(2000).Div(third, RoundDown) }, 666},
+		{"div negative", func() (Decimal, error) { return Decimal(-2000).Div(third, RoundHalfUp) }, -667},
+		{"div by negative", func() (Decimal, error) { return Decimal(2000).Div(third.Neg(), RoundHalfEven) }, -667},
+		// 10.00 / 0.125 = 80
+		{"div by fraction", func() (Decimal, error) { return Decimal(1000).Div(NewQuantity(125, 3), RoundHalfUp) }, 8000},
+
+		// 25% of 99.99 = 24.9975
+		{"percent", func() (Decimal, error) { return Decimal(9999).Percent(NewQuantity(25, 0), RoundHalfUp) }, 2500},
+		// 12% of 0.13 = 0.0156
+		{"percent small", func() (Decimal, error) { return Decimal(13).Percent(NewQuantity(12, 0), RoundHalfUp) }, 2},
+		// 12.5% of 100.00 = 12.50
+		{"percent fraction", func() (Decimal, error) { return Decimal(10000).Percent(NewQuantity(125, 1), RoundHalfUp) }, 1250},
+
+		{"round kronor", func() (Decimal, error) { return Decimal(12350).Round(0, RoundHalfUp) }, 12400},
+		{"round kronor half even", func() (Decimal, error) { return Decimal(12250).Round(0, RoundHalfEven) }, 12200},
+		{"round kronor negative", func() (Decimal, error) { return Decimal(-12350).Round(0, RoundHalfUp) }, -12400},
+		{"round tenths", func() (Decimal, error) { return Decimal(12345).Round(1, RoundHalfEven) }, 12340},
+		{"round hundreds", func() (Decimal, error) { return Decimal(1234500).Round(-2, RoundHalfUp) }, 1230000},
+		{"round cents", func() (Decimal, error) { return Decimal(12345).Round(2, RoundDown) }, 12345},
+
+		// Intermediate results larger than int64
+		{"mul large", func() (Decimal, error) { return Decimal(9e15).Mul(NewQuantity(10, 0), RoundHalfUp) }, 9e16},
+	}
+	for _, tc := range cases {
+		got, err := tc.fn()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if got != tc.exp {
+			t.Errorf("%s: got %d, expected %d", tc.name, got, tc.exp)
+		}
+	}
+
+	if _, err := Decimal(9e18).Mul(NewQuantity(2, 0), RoundHalfUp); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if _, err := Decimal(math.MaxInt64).Round(-2, RoundHalfUp); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if _, err := Decimal(100).Div(Quantity{}, RoundHalfUp); err == nil {
+		t.Error("expected error dividing by zero")
+	}
+}
+
+func TestDecimalText(t *testing.T) {
+	var v struct {
+		Amounts map[string]Decimal
+	}
+	if err := json.Unmarshal([]byte(`{"Amounts": {"a": 12.5}}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Amounts["a"] != 1250 {
+		t.Errorf("unexpected amount %d", v.Amounts["a"])
+	}
+
+	var d Decimal
+	if err := d.UnmarshalText([]byte("-0.125")); err != nil {
+		t.Fatal(err)
+	}
+	if d != -13 {
+		t.Errorf("unexpected amount %d", d)
+	}
+	if b, _ := d.MarshalText(); string(b) != "-0.13" {
+		t.Errorf("unexpected text %q", b)
+	}
+}
+
+func TestDecimalFloatString(t *testing.T) {
+	cases := []struct {
+		d        Decimal
+		decimals int
+		exp      string
+	}{
+		{-50, 0, "-1"},
+		{-50, 1, "-0.5"},
+		{-50, 2, "-0.50"},
+		{-50, 3, "-0.500"},
+		{-49, 0, "0"},
+		{-4, 1, "0.0"},
+		{-5, 1, "-0.1"},
+		{123456, 0, "1235"},
+		{123456, 1, "1234.6"},
+		{123456, 3, "1234.560"},
+		{-123445, 1, "-1234.5"},
+		{7, 3, "0.070"},
+	}
+	for _, tc := range cases {
+		if got := tc.d.FloatString(tc.decimals); got != tc.exp {
+			t.Errorf("%d with %d decimals: got %q, expected %q", tc.d, tc.decimals, got, tc.exp)
+		}
+	}
+}
+
+func TestDecimalSQL(t *testing.T) {
+	for _, d := range []Decimal{0, 1, -50, 123450, -123456} {
+		v, err := d.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Decimal
+		if err := got.Scan(v); err != nil {
+			t.Errorf("%v: %v", v, err)
+		} else if got != d {
+			t.Errorf("%v: got %d, expected %d", v, got, d)
+		}
+	}
+	if v, err := Decimal(-123450).Value(); err != nil || v != "-1234.50" {
+		t.Errorf("unexpected value %v, %v", v, err)
+	}
+
+	cases := []struct {
+		src any
+		exp Decimal
+	}{
+		{nil, 0},
+		{[]byte("-1234.50"), -123450},
+		{"0.01", 1},
+		{"12", 1200},
+		{int64(42), 4200}, // whole kronor
+		{int64(-3), -300},
+	}
+	for _, tc := range cases {
+		var d Decimal
+		if err := d.Scan(tc.src); err != nil {
+			t.Errorf("%v: %v", tc.src, err)
+		} else if d != tc.exp {
+			t.Errorf("%v: got %d, expected %d", tc.src, d, tc.exp)
+		}
+	}
+
+	// Anything that isn't read back exactly is an error
+	for _, src := range []any{true, 12.5, 12.345, int64(math.MaxInt64), "0.125", "99999999999999999999"} {
+		var d Decimal
+		if err := d.Scan(src); err == nil {
+			t.Errorf("expected error scanning %v, got %d", src, d)
+		}
+	}
+}
@@ -1,13 +1,22 @@
 package excel
 
 import (
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/xuri/excelize/v2"
 	"kastelo.dev/sie"
 )
 
+// BalanceXLSX returns the balance sheet using the BAS layout.
 func BalanceXLSX(doc *sie.Document) ([]byte, error) {
+	return BalanceXLSXWithLayout(doc, BASLayout())
+}
+
+// BalanceXLSXWithLayout returns the balance sheet with the accounts grouped
+// according to the layout.
+func BalanceXLSXWithLayout(doc *sie.Document, layout *Layout) ([]byte, error) {
 	xlsx := excelize.NewFile()
 
 	_ = xlsx.SetAppProps(&excelize.AppProperties{
@@ -17,14 +26,29 @@ func BalanceXLSX(doc *sie.Document) ([]byte, error) {
 	})
 
 	sheet := xlsx.GetSheetName(xlsx.GetActiveSheetIndex())
+	setBalanceColWidths(xlsx, sheet)
+	writeBalanceSheet(xlsx, sheet, doc, &layout.Balance)
+	_ = xlsx.SetSheetName(sheet, "Balansräkning")
 
-	// Set column widths
-	_ = xlsx.SetColWidth(sheet, "A", "A", 8)
-	_ = xlsx.SetColWidth(sheet, "B", "B", 50)
-	_ = xlsx.SetColWidth(sheet, "C", "E", 15)
+	// For each object with balances of its own, create a new sheet
 
-	writeBalanceSheet(xlsx, sheet, doc)
-	_ = xlsx.SetSheetName(sheet, "Balansräkning")
+	for _, annotation := range annotationsByDimension(doc) {
+		if !hasObjectBalances(doc, annotation) {
+			continue
+		}
+		name := sheetName(doc, annotation)
+		if idx, _ := xlsx.GetSheetIndex(name); idx != -1 {
+			continue
+		}
+		if _, err := xlsx.NewSheet(name); err != nil {
+			return nil, err
+		}
+		setBalanceColWidths(xlsx, name)
+		writeBalanceSheet(xlsx, name, doc.CopyForAnnotation(annotation), &layout.Balance)
+	}
+
+	xlsx.SetActiveSheet(0)
+	setPageHeaders(xlsx, doc)
 
 	// Increase size of window
 	for i := range xlsx.WorkBook.BookViews.WorkBookView {
@@ -41,103 +65,91 @@ func BalanceXLSX(doc *sie.Document) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func writeBalanceSheet(xlsx *excelize.File, sheet string, doc *sie.Document) {
-	state := 0
-	var inSum, outSum sie.Decimal
-	var assets, liabilities sie.Decimal
-	row := 1
+func setBalanceColWidths(xlsx *excelize.File, sheet string) {
+	_ = xlsx.SetColWidth(sheet, "A", "A", 8)
+	_ = xlsx.SetColWidth(sheet, "B", "B", 50)
+	_ = xlsx.SetColWidth(sheet, "C", "E", 15)
+}
 
-loop:
+// hasObjectBalances returns true if any account has a current year
+// opening or closing balance for the object.
+func hasObjectBalances(doc *sie.Document, ann sie.Annotation) bool {
 	for _, acc := range doc.Accounts {
-		switch {
-		case state == 0 && acc.ID >= 1000 && acc.ID <= 1999:
+		for _, ob := range acc.ObjectBalances {
+			if ob.Year == 0 && ob.Annotation.Equals(ann) && (ob.In != 0 || ob.Out != 0) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeBalanceSheet(xlsx *excelize.File, sheet string, doc *sie.Document, layout *BalanceLayout) {
+	var result sie.Decimal
+	row := 1
+
+	first := true
+	for i, sec := range layout.Sections {
+		if !slices.ContainsFunc(doc.Accounts, func(acc sie.Account) bool { return inBalanceSection(layout, doc.AccountPlan, acc, i) }) {
+			continue
+		}
+		if first {
 			style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), thickBorder("top")))
 			_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('F', row), style)
 			row++
+			first = false
+		}
 
-			_ = xlsx.SetCellValue(sheet, cell('B', row), "Tillgångar")
-			_ = xlsx.SetCellValue(sheet, cell('C', row), "Ing balans")
-			_ = xlsx.SetCellValue(sheet, cell('D', row), "Period")
-			_ = xlsx.SetCellValue(sheet, cell('E', row), "Utg balans")
-			style, _ = xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), thinBorder("bottom")))
+		_ = xlsx.SetCellValue(sheet, cell('B', row), sec.Name)
+		_ = xlsx.SetCellValue(sheet, cell('C', row), "Ing balans")
+		_ = xlsx.SetCellValue(sheet, cell('D', row), "Period")
+		_ = xlsx.SetCellValue(sheet, cell('E', row), "Utg balans")
+		style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), thinBorder("bottom")))
+		_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('B', row), style)
+		style, _ = xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), thinBorder("bottom"), textAlignment("right")))
+		_ = xlsx.SetCellStyle(sheet, cell('C', row), cell('E', row), style)
+		row++
+
+		var inSum, outSum sie.Decimal
+		for _, acc := range doc.Accounts {
+			if !inBalanceSection(layout, doc.AccountPlan, acc, i) {
+				continue
+			}
+			if acc.InBalance == 0 && acc.OutBalance == 0 {
+				continue
+			}
+
+			in, out := layout.Sign.apply(acc.InBalance), layout.Sign.apply(acc.OutBalance)
+			inSum += in
+			outSum += out
+
+			_ = xlsx.SetCellValue(sheet, cell('A', row), acc.ID)
+			_ = xlsx.SetCellValue(sheet, cell('B', row), acc.Description)
+			_ = xlsx.SetCellValue(sheet, cell('C', row), in.Float64())
+			_ = xlsx.SetCellValue(sheet, cell('D', row), (out - in).Float64())
+			_ = xlsx.SetCellValue(sheet, cell('E', row), out.Float64())
+			style, _ := xlsx.NewStyle(mergeStyles(defaultStyle()))
 			_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('B', row), style)
-			style, _ = xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), thinBorder("bottom"), textAlignment("right")))
+			style, _ = xlsx.NewStyle(mergeStyles(defaultStyle(), customNumberFormat()))
 			_ = xlsx.SetCellStyle(sheet, cell('C', row), cell('E', row), style)
-			row++
 
-			state = 1
-
-		case state == 1 && acc.ID >= 2000 && acc.ID <= 2999:
-			_ = xlsx.SetCellValue(sheet, cell('A', row), "")
-			_ = xlsx.SetCellValue(sheet, cell('B', row), "Summa tillgångar")
-			_ = xlsx.SetCellValue(sheet, cell('C', row), inSum.Float64())
-			_ = xlsx.SetCellValue(sheet, cell('D', row), (outSum - inSum).Float64())
-			_ = xlsx.SetCellValue(sheet, cell('E', row), outSum.Float64())
-			inSum = 0
-			outSum = 0
-			style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), customNumberFormat(), thickBorder("top")))
-			_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('E', row), style)
-			row++
-			_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('E', row), style)
 			row++
-
-			_ = xlsx.SetCellValue(sheet, cell('B', row), "Eget kapital, skulder")
-			style, _ = xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), thinBorder("bottom")))
-			_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('B', row), style)
-			_ = xlsx.SetCellValue(sheet, cell('C', row), "Ing balans")
-			_ = xlsx.SetCellValue(sheet, cell('D', row), "Period")
-			_ = xlsx.SetCellValue(sheet, cell('E', row), "Utg balans")
-			style, _ = xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), thinBorder("bottom"), textAlignment("right")))
 			_ = xlsx.SetCellStyle(sheet, cell('C', row), cell('E', row), style)
-			row++
-			state = 2
-
-		case acc.ID >= 3000:
-			_ = xlsx.SetCellValue(sheet, cell('A', row), "")
-			_ = xlsx.SetCellValue(sheet, cell('B', row), "Summa eget kapital, skulder")
-			_ = xlsx.SetCellValue(sheet, cell('C', row), inSum.Float64())
-			_ = xlsx.SetCellValue(sheet, cell('D', row), (outSum - inSum).Float64())
-			_ = xlsx.SetCellValue(sheet, cell('E', row), outSum.Float64())
-			inSum = 0
-			outSum = 0
-			style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), customNumberFormat(), thickBorder("top")))
-			_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('E', row), style)
-			row++
-			_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('E', row), style)
-			row++
-			break loop
-		}
-
-		if acc.InBalance == 0 && acc.OutBalance == 0 {
-			continue
 		}
-
-		inSum += acc.InBalance
-		outSum += acc.OutBalance
-
-		switch state {
-		case 1:
-			assets += acc.OutBalance
-		case 2:
-			liabilities += acc.OutBalance
-		}
-
-		_ = xlsx.SetCellValue(sheet, cell('A', row), acc.ID)
-		_ = xlsx.SetCellValue(sheet, cell('B', row), acc.Description)
-		_ = xlsx.SetCellValue(sheet, cell('C', row), acc.InBalance.Float64())
-		_ = xlsx.SetCellValue(sheet, cell('D', row), (acc.OutBalance - acc.InBalance).Float64())
-		_ = xlsx.SetCellValue(sheet, cell('E', row), acc.OutBalance.Float64())
-		style, _ := xlsx.NewStyle(mergeStyles(defaultStyle()))
-		_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('B', row), style)
-		style, _ = xlsx.NewStyle(mergeStyles(defaultStyle(), customNumberFormat()))
-		_ = xlsx.SetCellStyle(sheet, cell('C', row), cell('E', row), style)
-
+		result += outSum
+
+		_ = xlsx.SetCellValue(sheet, cell('A', row), "")
+		_ = xlsx.SetCellValue(sheet, cell('B', row), sec.Total)
+		_ = xlsx.SetCellValue(sheet, cell('C', row), inSum.Float64())
+		_ = xlsx.SetCellValue(sheet, cell('D', row), (outSum - inSum).Float64())
+		_ = xlsx.SetCellValue(sheet, cell('E', row), outSum.Float64())
+		style, _ = xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), customNumberFormat(), thickBorder("top")))
+		_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('E', row), style)
+		row++
+		_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('E', row), style)
 		row++
-		_ = xlsx.SetCellStyle(sheet, cell('C', row), cell('E', row), style)
 	}
 
-	result := assets + liabilities
-
 	style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), thickBorder("bottom")))
 	_ = xlsx.SetCellStyle(sheet, cell('A', row), cell('E', row), style)
 	row++
@@ -153,17 +165,42 @@ loop:
 	_ = xlsx.SetCellStyle(sheet, cell('A', row+1), cell('F', row+1), style)
 }
 
+// inBalanceSection returns true if the account is a balance account
+// belonging to the i:th section of the layout.
+func inBalanceSection(layout *BalanceLayout, plan string, acc sie.Account, i int) bool {
+	return acc.IsBalanceAccount(plan) && sectionOf(layout.Sections, acc, plan) == i
+}
+
 func balances(doc *sie.Document) map[int]*balance {
 	balances := make(map[int]*balance)
 	for _, acc := range doc.Accounts {
 		balances[acc.ID] = newBalance()
 		if acc.InBalance != 0 {
-			balances[acc.ID].add(time.Time{}, time.Time{}, acc.InBalance)
+			balances[acc.ID].add(time.Time{}, cellValue{amount: acc.InBalance})
 		}
 	}
 	for _, entry := range doc.Entries {
-		for _, tran := range entry.Transactions {
-			balances[tran.AccountID].add(entry.Date, entry.Filed, tran.Amount)
+		for _, tran := range entry.EffectiveTransactions() {
+			balances[tran.AccountID].add(entry.Date, cellValue{
+				amount: tran.Amount,
+				when:   entry.Filed,
+				date:   tran.Date,
+				text:   fmt.Sprintf("%s%s %s", entry.Type, entry.ID, tran.Text),
+			})
+		}
+	}
+	if len(doc.Entries) == 0 {
+		// Files without vouchers (SIE type 2 and 3) carry the period
+		// results as #PSALDO instead
+		for _, acc := range doc.Accounts {
+			if !acc.IsResultAccount(doc.AccountPlan) {
+				continue
+			}
+			for _, pb := range acc.Periods {
+				if pb.Year == 0 && len(pb.Annotations) == 0 {
+					balances[acc.ID].add(pb.Period, cellValue{amount: pb.Amount})
+				}
+			}
 		}
 	}
 	return balances
@@ -176,7 +213,9 @@ type balance struct {
 
 type cellValue struct {
 	amount sie.Decimal
-	when   time.Time
+	when   time.Time // when filed
+	date   time.Time // transaction date
+	text   string
 }
 
 func newBalance() *balance {
@@ -185,10 +224,10 @@ func newBalance() *balance {
 	}
 }
 
-func (b *balance) add(date, filed time.Time, amount sie.Decimal) {
-	b.total += amount
+func (b *balance) add(date time.Time, v cellValue) {
+	b.total += v.amount
 	key := date.Format("2006-01")
-	b.months[key] = append(b.months[key], cellValue{amount: amount, when: filed})
+	b.months[key] = append(b.months[key], v)
 }
 
 func (b *balance) inverse() *balance {
@@ -196,7 +235,9 @@ func (b *balance) inverse() *balance {
 	new.total -= b.total
 	for m, v := range b.months {
 		for i := range v {
-			new.months[m] = append(new.months[m], cellValue{amount: -v[i].amount, when: v[i].when})
+			cv := v[i]
+			cv.amount = -cv.amount
+			new.months[m] = append(new.months[m], cv)
 		}
 	}
 	return new
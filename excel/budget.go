@@ -0,0 +1,284 @@
+package excel
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"kastelo.dev/sie"
+)
+
+// Each month (and the total) is shown as a group of four columns: actual,
+// budget, variance and variance in percent of the budget.
+const budgetGroupCols = 4
+
+// BudgetXLSX returns the outcome against the budget, per month, using the
+// BAS layout.
+func BudgetXLSX(doc *sie.Document) ([]byte, error) {
+	return BudgetXLSXWithLayout(doc, BASLayout())
+}
+
+// BudgetXLSXWithLayout returns the outcome against the budget, per month,
+// with the accounts grouped according to the result layout.
+func BudgetXLSXWithLayout(doc *sie.Document, layout *Layout) ([]byte, error) {
+	xlsx := excelize.NewFile()
+
+	_ = xlsx.SetAppProps(&excelize.AppProperties{
+		Application: "kastelo.dev/sie",
+		Company:     "Kastelo AB",
+		DocSecurity: 2,
+	})
+
+	sheet := xlsx.GetSheetName(xlsx.GetActiveSheetIndex())
+	if err := writeBudgetSheet(xlsx, sheet, doc, &layout.Result); err != nil {
+		return nil, err
+	}
+	_ = xlsx.SetSheetName(sheet, "Budget")
+	setPageHeaders(xlsx, doc)
+
+	// Increase size of window
+	for i := range xlsx.WorkBook.BookViews.WorkBookView {
+		xlsx.WorkBook.BookViews.WorkBookView[i].XWindow = "1000"
+		xlsx.WorkBook.BookViews.WorkBookView[i].YWindow = "1000"
+		xlsx.WorkBook.BookViews.WorkBookView[i].WindowWidth = 25000
+		xlsx.WorkBook.BookViews.WorkBookView[i].WindowHeight = 25000 / 3 * 2
+	}
+
+	buf, err := xlsx.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeBudgetSheet(xlsx *excelize.File, sheet string, doc *sie.Document, layout *ResultLayout) error {
+	var months []time.Time
+	for t := doc.Starts; !t.After(doc.Ends); t = t.AddDate(0, 1, 0) {
+		months = append(months, t)
+	}
+	// Month groups start at column C, the total group follows after an
+	// empty column
+	groupCols := make([]int, 0, len(months)+1)
+	for i := range months {
+		groupCols = append(groupCols, 3+i*budgetGroupCols)
+	}
+	totalCol := 3 + len(months)*budgetGroupCols + 1
+	groupCols = append(groupCols, totalCol)
+	lastCol := totalCol + budgetGroupCols - 1
+
+	_ = xlsx.SetColWidth(sheet, "A", "A", 8)
+	_ = xlsx.SetColWidth(sheet, "B", "B", 45)
+	firstName, _ := excelize.ColumnNumberToName(3)
+	lastName, _ := excelize.ColumnNumberToName(lastCol)
+	_ = xlsx.SetColWidth(sheet, firstName, lastName, 10)
+
+	style, _ := xlsx.NewStyle(defaultStyle())
+	_ = xlsx.SetCellStyle(sheet, cellAt(1, 1), cellAt(lastCol+1, 1000), style)
+
+	// Two header rows; the month (or total) across each group, then the
+	// column names
+
+	hdrStyle, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), textAlignment("center")))
+	subStyle, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), textAlignment("right"), thinBorder("bottom")))
+	for i, col := range groupCols {
+		hdr := "Total"
+		if i < len(months) {
+			hdr = months[i].Format("2006-01")
+		}
+		_ = xlsx.SetCellValue(sheet, cellAt(col, 1), hdr)
+		_ = xlsx.MergeCell(sheet, cellAt(col, 1), cellAt(col+budgetGroupCols-1, 1))
+		_ = xlsx.SetCellStyle(sheet, cellAt(col, 1), cellAt(col+budgetGroupCols-1, 1), hdrStyle)
+		for j, name := range []string{"Utfall", "Budget", "Avvikelse", "Avv. %"} {
+			_ = xlsx.SetCellValue(sheet, cellAt(col+j, 2), name)
+		}
+		_ = xlsx.SetCellStyle(sheet, cellAt(col, 2), cellAt(col+budgetGroupCols-1, 2), subStyle)
+	}
+
+	_ = xlsx.SetPanes(sheet, &excelize.Panes{
+		ActivePane:  "bottomRight",
+		Freeze:      true,
+		XSplit:      2,
+		YSplit:      2,
+		TopLeftCell: "C3",
+	})
+
+	actuals := balances(doc)
+	budgets := budgetBalances(doc)
+
+	row := 3
+	var sumRows []int
+	for i, sec := range layout.Sections {
+		var accounts []sie.Account
+		for _, acc := range doc.Accounts {
+			if !acc.IsResultAccount(doc.AccountPlan) || sectionOf(layout.Sections, acc, doc.AccountPlan) != i {
+				continue
+			}
+			if actuals[acc.ID].total == 0 && len(budgets[acc.ID]) == 0 {
+				continue
+			}
+			accounts = append(accounts, acc)
+		}
+		if len(accounts) == 0 {
+			continue
+		}
+
+		row++
+		_ = xlsx.SetCellValue(sheet, cellAt(2, row), sec.Name)
+		style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), thinBorder("bottom")))
+		_ = xlsx.SetCellStyle(sheet, cellAt(2, row), cellAt(lastCol, row), style)
+		row++
+		startRow := row
+
+		for _, acc := range accounts {
+			actual := layout.Sign.balance(actuals[acc.ID])
+			_ = xlsx.SetCellInt(sheet, cellAt(1, row), acc.ID)
+			_ = xlsx.SetCellValue(sheet, cellAt(2, row), acc.Description)
+			for i, t := range months {
+				var amount sie.Decimal
+				for _, cv := range actual.months[t.Format("2006-01")] {
+					amount += cv.amount
+				}
+				col := groupCols[i]
+				if amount != 0 {
+					_ = xlsx.SetCellValue(sheet, cellAt(col, row), amount.Float64())
+				}
+				if budget, ok := budgets[acc.ID][t.Format("2006-01")]; ok {
+					_ = xlsx.SetCellValue(sheet, cellAt(col+1, row), layout.Sign.apply(budget).Float64())
+				}
+			}
+			xlsxBudgetTotals(xlsx, sheet, row, groupCols)
+			xlsxBudgetVariances(xlsx, sheet, row, groupCols)
+			xlsxBudgetRowStyle(xlsx, sheet, row, groupCols, false)
+			row++
+		}
+
+		for _, col := range groupCols {
+			for _, c := range []int{col, col + 1} {
+				_ = xlsx.SetCellFormula(sheet, cellAt(c, row), fmt.Sprintf("SUM(%s:%s)", cellAt(c, startRow), cellAt(c, row-1)))
+			}
+		}
+		xlsxBudgetVariances(xlsx, sheet, row, groupCols)
+		xlsxBudgetRowStyle(xlsx, sheet, row, groupCols, true)
+		sumRows = append(sumRows, row)
+		row++
+	}
+
+	if len(sumRows) > 0 {
+		row++
+		_ = xlsx.SetCellValue(sheet, cellAt(2, row), "Resultat")
+		for _, col := range groupCols {
+			for _, c := range []int{col, col + 1} {
+				cells := make([]string, len(sumRows))
+				for i, r := range sumRows {
+					cells[i] = cellAt(c, r)
+				}
+				_ = xlsx.SetCellFormula(sheet, cellAt(c, row), strings.Join(cells, "+"))
+			}
+		}
+		xlsxBudgetVariances(xlsx, sheet, row, groupCols)
+		xlsxBudgetRowStyle(xlsx, sheet, row, groupCols, true)
+	}
+
+	// Colour favourable variances green and unfavourable ones red. With
+	// credit sign income is positive and costs negative, so a positive
+	// variance is favourable; with debit sign it's the other way around.
+	good, err := xlsx.NewConditionalStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "#006100"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#C6EFCE"}, Pattern: 1},
+	})
+	if err != nil {
+		return err
+	}
+	bad, err := xlsx.NewConditionalStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "#9C0006"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#FFC7CE"}, Pattern: 1},
+	})
+	if err != nil {
+		return err
+	}
+	if layout.Sign == SignDebit {
+		good, bad = bad, good
+	}
+	for _, col := range groupCols {
+		rng := fmt.Sprintf("%s:%s", cellAt(col+2, 3), cellAt(col+3, row))
+		err := xlsx.SetConditionalFormat(sheet, rng, []excelize.ConditionalFormatOptions{
+			{Type: "cell", Criteria: ">", Format: good, Value: "0"},
+			{Type: "cell", Criteria: "<", Format: bad, Value: "0"},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// xlsxBudgetTotals sets the actual and budget columns of the total group
+// to the sum of the month groups.
+func xlsxBudgetTotals(xlsx *excelize.File, sheet string, row int, groupCols []int) {
+	months := groupCols[:len(groupCols)-1]
+	total := groupCols[len(groupCols)-1]
+	for off := 0; off < 2; off++ {
+		cells := make([]string, len(months))
+		for i, col := range months {
+			cells[i] = cellAt(col+off, row)
+		}
+		_ = xlsx.SetCellFormula(sheet, cellAt(total+off, row), fmt.Sprintf("SUM(%s)", strings.Join(cells, ",")))
+	}
+}
+
+// xlsxBudgetVariances sets the variance and variance percentage columns of
+// each group.
+func xlsxBudgetVariances(xlsx *excelize.File, sheet string, row int, groupCols []int) {
+	for _, col := range groupCols {
+		actual, budget := cellAt(col, row), cellAt(col+1, row)
+		_ = xlsx.SetCellFormula(sheet, cellAt(col+2, row), fmt.Sprintf("%s-%s", actual, budget))
+		_ = xlsx.SetCellFormula(sheet, cellAt(col+3, row), fmt.Sprintf(`IF(%s=0,"",(%s-%s)/ABS(%s))`, budget, actual, budget, budget))
+	}
+}
+
+func xlsxBudgetRowStyle(xlsx *excelize.File, sheet string, row int, groupCols []int, sum bool) {
+	var extra []*excelize.Style
+	if sum {
+		extra = append(extra, fontBold(), thickBorder("top"))
+		style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), thickBorder("top")))
+		_ = xlsx.SetCellStyle(sheet, cellAt(1, row), cellAt(2, row), style)
+	}
+	numStyle, _ := xlsx.NewStyle(mergeStyles(append([]*excelize.Style{defaultStyle(), customNumberFormat()}, extra...)...))
+	pctStyle, _ := xlsx.NewStyle(mergeStyles(append([]*excelize.Style{defaultStyle(), percentFormat()}, extra...)...))
+	for _, col := range groupCols {
+		_ = xlsx.SetCellStyle(sheet, cellAt(col, row), cellAt(col+2, row), numStyle)
+		_ = xlsx.SetCellStyle(sheet, cellAt(col+3, row), cellAt(col+3, row), pctStyle)
+	}
+}
+
+// budgetBalances returns the current year's budget per account and month,
+// for budgets not tied to an object.
+func budgetBalances(doc *sie.Document) map[int]map[string]sie.Decimal {
+	budgets := make(map[int]map[string]sie.Decimal)
+	for _, acc := range doc.Accounts {
+		for _, pb := range acc.Budgets {
+			if pb.Year != 0 || len(pb.Annotations) != 0 {
+				continue
+			}
+			if budgets[acc.ID] == nil {
+				budgets[acc.ID] = make(map[string]sie.Decimal)
+			}
+			budgets[acc.ID][pb.Period.Format("2006-01")] += pb.Amount
+		}
+	}
+	return budgets
+}
+
+func percentFormat() *excelize.Style {
+	fmt := "0.0%"
+	return &excelize.Style{
+		CustomNumFmt: &fmt,
+	}
+}
+
+func cellAt(col, row int) string {
+	name, _ := excelize.CoordinatesToCellName(col, row)
+	return name
+}
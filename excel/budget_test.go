@@ -0,0 +1,56 @@
+package excel
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+	"kastelo.dev/sie"
+)
+
+func TestBudgetXLSX(t *testing.T) {
+	const input = "#SIETYP 3\r\n" +
+		"#RAR 0 20240101 20240229\r\n" +
+		"#KONTO 3001 \"Försäljning\"\r\n" +
+		"#PSALDO 0 202401 3001 {} -100000.00\r\n" +
+		"#PSALDO 0 202402 3001 {} -50000.00\r\n" +
+		"#PBUDGET 0 202401 3001 {} -80000.00\r\n" +
+		"#PBUDGET 0 202402 3001 {} -60000.00\r\n"
+
+	doc, err := sie.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := BudgetXLSX(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := excelize.OpenReader(bytes.NewReader(bs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Row 5 is the account, after the headers and the section name. The
+	// groups are January in C-F, February in G-J and the total in L-O.
+	if v, _ := x.GetCellValue("Budget", "A5"); v != "3001" {
+		t.Fatalf("account on row 5 is %q", v)
+	}
+	cases := []struct {
+		cell string
+		exp  string
+	}{
+		{"C2", "Utfall"}, {"D2", "Budget"}, {"E2", "Avvikelse"}, {"F2", "Avv. %"},
+		{"C5", "100000"}, {"D5", "80000"}, {"E5", "20000"}, {"F5", "0.25"},
+		{"G5", "50000"}, {"H5", "60000"}, {"I5", "-10000"}, {"J5", "-0.16666666666666666"},
+		{"L5", "150000"}, {"M5", "140000"}, {"N5", "10000"}, {"O5", "0.07142857142857142"},
+	}
+	for _, tc := range cases {
+		v, err := x.CalcCellValue("Budget", tc.cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			t.Errorf("%s: %v", tc.cell, err)
+		} else if v != tc.exp {
+			t.Errorf("%s: got %q, expected %q", tc.cell, v, tc.exp)
+		}
+	}
+}
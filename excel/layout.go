@@ -0,0 +1,233 @@
+package excel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"kastelo.dev/sie"
+)
+
+// Layout describes how the accounts are grouped and signed in the result,
+// balance and budget reports. The default is BASLayout; others can be
+// loaded from JSON with LoadLayout, for example:
+//
+//	{
+//	  "name": "Stiftelse",
+//	  "result": {
+//	    "sign": "credit",
+//	    "sections": [
+//	      {"name": "Intäkter", "ranges": [{"from": 3000, "to": 3999}]},
+//	      {"name": "Kostnader", "ranges": [{"from": 4000, "to": 7999}]}
+//	    ],
+//	    "subtotals": [
+//	      {"name": "Verksamhetsresultat", "sections": ["Intäkter", "Kostnader"], "after": "Kostnader"}
+//	    ]
+//	  },
+//	  "balance": {
+//	    "sign": "debit",
+//	    "sections": [
+//	      {"name": "Tillgångar", "total": "Summa tillgångar", "types": ["T"]},
+//	      {"name": "Eget kapital, skulder", "total": "Summa eget kapital, skulder", "types": ["S"]}
+//	    ]
+//	  }
+//	}
+type Layout struct {
+	Name    string        `json:"name"`
+	Result  ResultLayout  `json:"result"`
+	Balance BalanceLayout `json:"balance"`
+}
+
+type ResultLayout struct {
+	Sign     Sign      `json:"sign"`
+	Sections []Section `json:"sections"`
+
+	// Subtotals are sums over several sections, shown after one of them.
+	Subtotals []Subtotal `json:"subtotals,omitempty"`
+
+	// CapitalAccounts are the equity accounts that, together with the
+	// result, make up the running equity shown on the main sheet.
+	CapitalAccounts []int `json:"capitalAccounts,omitempty"`
+}
+
+type BalanceLayout struct {
+	Sign     Sign      `json:"sign"`
+	Sections []Section `json:"sections"`
+}
+
+// Section is a group of accounts shown under a common heading, with a sum
+// row labelled Total. The accounts are selected by number range, by type,
+// or both. An account belongs to the first section containing it.
+type Section struct {
+	Name   string            `json:"name"`
+	Total  string            `json:"total,omitempty"`
+	Ranges []AccountRange    `json:"ranges,omitempty"`
+	Types  []sie.AccountType `json:"types,omitempty"`
+}
+
+// AccountRange is an inclusive range of account numbers.
+type AccountRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+type Subtotal struct {
+	Name     string   `json:"name"`
+	Sections []string `json:"sections"`
+	After    string   `json:"after"`
+}
+
+// Sign is the sign convention for amounts in a report.
+type Sign string
+
+const (
+	// SignDebit shows amounts as booked, with debits positive.
+	SignDebit Sign = "debit"
+	// SignCredit shows credits positive, so that income is positive and
+	// costs negative.
+	SignCredit Sign = "credit"
+)
+
+// BASLayout returns the layout for the BAS chart of accounts.
+func BASLayout() *Layout {
+	return &Layout{
+		Name: "BAS",
+		Result: ResultLayout{
+			Sign: SignCredit,
+			Sections: []Section{
+				{Name: "Nettoomsättning", Ranges: []AccountRange{{3000, 3799}}},
+				{Name: "Aktiverat arbete för egen räkning", Ranges: []AccountRange{{3800, 3899}}},
+				{Name: "Övriga rörelseintäkter", Ranges: []AccountRange{{3900, 3999}}},
+				{Name: "Varukostnader", Ranges: []AccountRange{{4000, 4999}}},
+				{Name: "Externa kostnader", Ranges: []AccountRange{{5000, 6999}}},
+				{Name: "Personalkostnader", Ranges: []AccountRange{{7000, 7699}}},
+				{Name: "Av- och nedskrivningar", Ranges: []AccountRange{{7700, 7899}}},
+				{Name: "Övriga rörelsekostnader", Ranges: []AccountRange{{7900, 7999}}},
+				{Name: "Finansiella poster", Ranges: []AccountRange{{8000, 8998}}},
+			},
+			Subtotals: []Subtotal{
+				{
+					Name:     "Rörelsens intäkter",
+					Sections: []string{"Nettoomsättning", "Aktiverat arbete för egen räkning", "Övriga rörelseintäkter"},
+					After:    "Övriga rörelseintäkter",
+				},
+				{
+					Name:     "Rörelsens kostnader",
+					Sections: []string{"Varukostnader", "Externa kostnader", "Personalkostnader"},
+					After:    "Personalkostnader",
+				},
+				{
+					Name:     "Rörelseresultat",
+					Sections: []string{"Nettoomsättning", "Aktiverat arbete för egen räkning", "Övriga rörelseintäkter", "Varukostnader", "Externa kostnader", "Personalkostnader"},
+					After:    "Personalkostnader",
+				},
+			},
+			CapitalAccounts: []int{
+				2081, // aktiekapital
+				2091, // balanserat eget kapital
+				2098, // förra årets resultat
+			},
+		},
+		Balance: BalanceLayout{
+			Sign: SignDebit,
+			Sections: []Section{
+				{Name: "Tillgångar", Total: "Summa tillgångar", Types: []sie.AccountType{sie.Asset}},
+				{Name: "Eget kapital, skulder", Total: "Summa eget kapital, skulder", Types: []sie.AccountType{sie.Liability}},
+			},
+		},
+	}
+}
+
+// LoadLayout reads a layout in JSON format and checks it for consistency.
+func LoadLayout(r io.Reader) (*Layout, error) {
+	var l Layout
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&l); err != nil {
+		return nil, err
+	}
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Validate checks that the layout has sections with valid ranges and
+// signs, and that subtotals refer to existing sections.
+func (l *Layout) Validate() error {
+	var errs []error
+	check := func(report string, sign Sign, sections []Section) {
+		if sign != SignDebit && sign != SignCredit {
+			errs = append(errs, fmt.Errorf("%s: invalid sign %q", report, sign))
+		}
+		if len(sections) == 0 {
+			errs = append(errs, fmt.Errorf("%s: no sections", report))
+		}
+		for _, sec := range sections {
+			if len(sec.Ranges) == 0 && len(sec.Types) == 0 {
+				errs = append(errs, fmt.Errorf("%s: section %q has neither account ranges nor types", report, sec.Name))
+			}
+			for _, r := range sec.Ranges {
+				if r.From > r.To {
+					errs = append(errs, fmt.Errorf("%s: section %q has invalid range %d-%d", report, sec.Name, r.From, r.To))
+				}
+			}
+		}
+	}
+	check("result", l.Result.Sign, l.Result.Sections)
+	check("balance", l.Balance.Sign, l.Balance.Sections)
+
+	hasSection := func(name string) bool {
+		return slices.ContainsFunc(l.Result.Sections, func(sec Section) bool { return sec.Name == name })
+	}
+	for _, sum := range l.Result.Subtotals {
+		for _, name := range append([]string{sum.After}, sum.Sections...) {
+			if !hasSection(name) {
+				errs = append(errs, fmt.Errorf("result: subtotal %q refers to unknown section %q", sum.Name, name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Contains returns true if the account is within one of the section's
+// ranges and of one of its types. The plan is the #KPTYP of the document,
+// as for sie.Account.EffectiveType.
+func (s Section) Contains(acc sie.Account, plan string) bool {
+	if len(s.Types) > 0 && !slices.Contains(s.Types, acc.EffectiveType(plan)) {
+		return false
+	}
+	if len(s.Ranges) == 0 {
+		return true
+	}
+	for _, r := range s.Ranges {
+		if r.From <= acc.ID && acc.ID <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionOf returns the index of the first section containing the
+// account, or -1.
+func sectionOf(sections []Section, acc sie.Account, plan string) int {
+	return slices.IndexFunc(sections, func(sec Section) bool { return sec.Contains(acc, plan) })
+}
+
+// apply returns the booked amount d as shown with the sign convention.
+func (s Sign) apply(d sie.Decimal) sie.Decimal {
+	if s == SignCredit {
+		return -d
+	}
+	return d
+}
+
+// balance returns the booked balance b as shown with the sign convention.
+func (s Sign) balance(b *balance) *balance {
+	if s == SignCredit {
+		return b.inverse()
+	}
+	return b
+}
@@ -0,0 +1,154 @@
+package excel
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+	"kastelo.dev/sie"
+)
+
+func TestLoadLayout(t *testing.T) {
+	bs, err := json.Marshal(BASLayout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := LoadLayout(bytes.NewReader(bs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(l, BASLayout()) {
+		t.Errorf("layout does not survive a JSON round trip:\n%+v", l)
+	}
+
+	cases := []struct {
+		json string
+		err  string
+	}{
+		{`{"result": {"sign": "up"}}`, `result: invalid sign "up"`},
+		{`{"result": {"sign": "credit"}}`, `result: no sections`},
+		{`{"result": {"sign": "credit", "sections": [{"name": "A", "ranges": [{"from": 4000, "to": 3000}]}]}}`, `section "A" has invalid range 4000-3000`},
+		{`{"result": {"sign": "credit", "sections": [{"name": "A", "ranges": [{"from": 3000, "to": 3999}]}], "subtotals": [{"name": "S", "sections": ["B"], "after": "A"}]}}`, `subtotal "S" refers to unknown section "B"`},
+		{`{"results": {}}`, `unknown field "results"`},
+	}
+	for _, tc := range cases {
+		_, err := LoadLayout(strings.NewReader(tc.json))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("LoadLayout(%s): got error %v, expected %q", tc.json, err, tc.err)
+		}
+	}
+}
+
+func TestReportsWithLayout(t *testing.T) {
+	fd, err := os.Open("../testdata/testdata.se")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	doc, err := sie.Parse(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := BASLayout()
+	l.Result.Sign = SignDebit
+	l.Result.Sections = []Section{{Name: "Kostnader", Ranges: []AccountRange{{4000, 7999}}}}
+	l.Result.Subtotals = nil
+	l.Balance.Sign = SignCredit
+
+	// The insurance cost in August, shown as booked with debit sign
+	bs, err := ResultXLSXWithLayout(doc, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCells(t, bs, "Totalt", map[string]string{
+		"B3": "Kostnader",
+		"A4": "6310",
+		"J1": "2016-08",
+		"J4": "1957",
+		"P1": "Total",
+		"P4": "1957",
+	})
+
+	// Credit sign turns the bank balance negative and the equity positive
+	bs, err = BalanceXLSXWithLayout(doc, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCells(t, bs, "Balansräkning", map[string]string{
+		"A3": "1930",
+		"E3": "-48043",
+		"A7": "2081",
+		"E7": "50000",
+	})
+
+	// The outcome without a budget; the August group starts at AE
+	bs, err = BudgetXLSXWithLayout(doc, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCells(t, bs, "Budget", map[string]string{
+		"B4":  "Kostnader",
+		"A5":  "6310",
+		"AE1": "2016-08",
+		"AE5": "1957",
+		"AG5": "1957",
+	})
+}
+
+// checkCells compares the calculated values of the cells in the sheet to
+// the expected ones.
+func checkCells(t *testing.T, xlsx []byte, sheet string, cells map[string]string) {
+	t.Helper()
+	x, err := excelize.OpenReader(bytes.NewReader(xlsx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cell, exp := range cells {
+		v, err := x.CalcCellValue(sheet, cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			t.Errorf("%s!%s: %v", sheet, cell, err)
+		} else if v != exp {
+			t.Errorf("%s!%s: got %q, expected %q", sheet, cell, v, exp)
+		}
+	}
+}
+
+func TestBalanceByAccountType(t *testing.T) {
+	// A chart where the numbers don't follow BAS; the types decide, and
+	// untyped accounts don't get BAS types from their numbers
+	doc := &sie.Document{
+		AccountPlan: "K1",
+		Accounts: []sie.Account{
+			{ID: 100, Type: sie.Asset, Description: "Kassa", InBalance: 10000, OutBalance: 20000},
+			{ID: 500, Type: sie.Liability, Description: "Eget kapital", InBalance: -10000, OutBalance: -10000},
+			{ID: 1500, Type: sie.Income, Description: "Försäljning", OutBalance: -10000},
+			{ID: 1930, Description: "Bank", InBalance: 5000, OutBalance: 5000},
+		},
+	}
+	bs, err := BalanceXLSX(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := excelize.OpenReader(bytes.NewReader(bs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := x.GetRows("Balansräkning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, row := range rows {
+		if len(row) > 0 && row[0] != "" {
+			ids = append(ids, row[0])
+		}
+	}
+	if got := strings.Join(ids, ","); got != "100,500" {
+		t.Errorf("got accounts %s on the balance sheet", got)
+	}
+}
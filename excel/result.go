@@ -11,42 +11,15 @@ import (
 	"kastelo.dev/sie"
 )
 
-type section struct {
-	name       string
-	start, end int
-}
-
-var sections = []section{
-	{"Nettoomsättning", 3000, 3799},
-	{"Aktiverat arbete för egen räkning", 3800, 3899},
-	{"Övriga rörelseintäkter", 3900, 3999},
-	{"Varukostnader", 4000, 4999},
-	{"Externa kostnader", 5000, 6999},
-	{"Personalkostnader", 7000, 7699},
-	{"Av- och nedskrivningar", 7700, 7899},
-	{"Övriga rörelsekostnader", 7900, 7999},
-	{"Finansiella poster", 8000, 8998},
-}
-
-type summary struct {
-	name        string
-	sectionIdxs []int
-	afterIdx    int
-}
-
-var summaries = []summary{
-	{"Rörelsens intäkter", []int{0, 1, 2}, 2},
-	{"Rörelsens kostnader", []int{3, 4, 5}, 5},
-	{"Rörelseresultat", []int{0, 1, 2, 3, 4, 5}, 5},
-}
-
-var currentCapitalAccounts = []int{
-	2081, // aktiekapital
-	2091, // balanserat eget kapital
-	2098, // förra årets resultat
+// ResultXLSX returns the income statement, per month, using the BAS
+// layout.
+func ResultXLSX(doc *sie.Document) ([]byte, error) {
+	return ResultXLSXWithLayout(doc, BASLayout())
 }
 
-func ResultXLSX(doc *sie.Document) ([]byte, error) {
+// ResultXLSXWithLayout returns the income statement, per month, with the
+// accounts grouped according to the layout.
+func ResultXLSXWithLayout(doc *sie.Document, layout *Layout) ([]byte, error) {
 	xlsx := excelize.NewFile()
 
 	_ = xlsx.SetAppProps(&excelize.AppProperties{
@@ -56,10 +29,10 @@ func ResultXLSX(doc *sie.Document) ([]byte, error) {
 	})
 
 	sheet := xlsx.GetSheetName(xlsx.GetActiveSheetIndex())
-	writeSheet(xlsx, sheet, doc, true)
+	writeSheet(xlsx, sheet, doc, &layout.Result, true)
 	_ = xlsx.SetSheetName(sheet, "Totalt")
 
-	// For each annotation, create a new sheet
+	// For each object, create a new sheet, grouped by dimension
 
 	type annotatedDoc struct {
 		name string
@@ -67,13 +40,13 @@ func ResultXLSX(doc *sie.Document) ([]byte, error) {
 	}
 
 	var docs []annotatedDoc
-	for _, annotation := range doc.Annotations {
+	for _, annotation := range annotationsByDimension(doc) {
 		filtered := doc.CopyForAnnotation(annotation)
-		if len(filtered.Entries) == 0 {
+		if !hasActivity(filtered) {
 			continue
 		}
 
-		name := annotation.String()
+		name := sheetName(doc, annotation)
 		found := false
 		for i := range docs {
 			if docs[i].name == name {
@@ -88,25 +61,36 @@ func ResultXLSX(doc *sie.Document) ([]byte, error) {
 	}
 
 	for _, adoc := range docs {
-		if len(adoc.doc.Entries) == 0 {
+		if !hasActivity(adoc.doc) {
 			continue
 		}
 		_, err := xlsx.NewSheet(adoc.name)
 		if err != nil {
 			return nil, err
 		}
-		writeSheet(xlsx, adoc.name, adoc.doc, false)
+		writeSheet(xlsx, adoc.name, adoc.doc, &layout.Result, false)
 	}
 
 	// If there were annotations, also produce a sheet for whatever remains
+	// in each dimension
 
-	if len(doc.Annotations) > 0 {
-		cpy := doc.CopyWithoutAnnotations()
-		_, _ = xlsx.NewSheet("(Other)")
-		writeSheet(xlsx, "(Other)", cpy, false)
+	var dims []int
+	for _, annotation := range annotationsByDimension(doc) {
+		if !slices.Contains(dims, annotation.Tag) {
+			dims = append(dims, annotation.Tag)
+		}
+	}
+	for _, dim := range dims {
+		name := "(Other)"
+		if len(dims) > 1 {
+			name = sheetName(doc, sie.Annotation{Tag: dim, Text: name})
+		}
+		_, _ = xlsx.NewSheet(name)
+		writeSheet(xlsx, name, doc.CopyWithoutDimension(dim), &layout.Result, false)
 	}
 
 	xlsx.SetActiveSheet(0)
+	setPageHeaders(xlsx, doc)
 
 	// Increase size of window
 	for i := range xlsx.WorkBook.BookViews.WorkBookView {
@@ -123,10 +107,8 @@ func ResultXLSX(doc *sie.Document) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func writeSheet(xlsx *excelize.File, sheet string, doc *sie.Document, withCapital bool) {
-	sec := -1
+func writeSheet(xlsx *excelize.File, sheet string, doc *sie.Document, layout *ResultLayout, withCapital bool) {
 	row := 1
-	startRow := 1
 	var sumRows []int
 
 	_ = xlsx.SetColWidth(sheet, "B", "B", 55)
@@ -136,18 +118,30 @@ func writeSheet(xlsx *excelize.File, sheet string, doc *sie.Document, withCapita
 	ey, em, _ := doc.Ends.Date()
 	numMonths := (ey-sy)*12 + int(em) - int(sm) + 1
 
+	// Previous year's result per account, shown next to the total on the
+	// main sheet when the file carries it
+	var prevResults map[int]sie.Decimal
+	if withCapital {
+		prevResults = previousResults(doc, layout.Sign)
+	}
+	withPrevious := len(prevResults) > 0
+	headerCols := numMonths
+	if withPrevious {
+		headerCols++
+	}
+
 	// Eget kapital vid årets ingång
 	var inCapital sie.Decimal
 	for _, acc := range doc.Accounts {
-		if slices.Contains(currentCapitalAccounts, acc.ID) {
-			inCapital -= acc.InBalance
+		if slices.Contains(layout.CapitalAccounts, acc.ID) {
+			inCapital += layout.Sign.apply(acc.InBalance)
 		}
 	}
 
 	style, _ := xlsx.NewStyle(defaultStyle())
 	_ = xlsx.SetCellStyle(sheet, cell('A', 1), cell('A'+rune(numMonths)+5, 1000), style)
 
-	xlsxHeaderMonths(xlsx, sheet, row, "", doc.Starts, doc.Ends)
+	xlsxHeaderMonths(xlsx, sheet, row, "", doc.Starts, doc.Ends, withPrevious)
 	row++
 
 	_ = xlsx.SetPanes(sheet, &excelize.Panes{
@@ -160,73 +154,58 @@ func writeSheet(xlsx *excelize.File, sheet string, doc *sie.Document, withCapita
 
 	accountBalance := balances(doc)
 	summarySumRows := make(map[string][]int)
-	for _, acc := range doc.Accounts {
-		bal, ok := accountBalance[acc.ID]
-		if !ok {
-			continue
+	for i, sec := range layout.Sections {
+		var accounts []sie.Account
+		for _, acc := range doc.Accounts {
+			bal, ok := accountBalance[acc.ID]
+			if !ok || bal.total == 0 && prevResults[acc.ID] == 0 {
+				continue
+			}
+			if acc.IsResultAccount(doc.AccountPlan) && sectionOf(layout.Sections, acc, doc.AccountPlan) == i {
+				accounts = append(accounts, acc)
+			}
 		}
-		if bal.total == 0 {
+		if len(accounts) == 0 {
 			continue
 		}
-		bal = bal.inverse()
 
-		newSec := -1
-		for i, sec := range sections {
-			id := acc.ID
-			if sec.start <= id && id <= sec.end {
-				newSec = i
-				break
-			}
+		row++
+		xlsxHeader(xlsx, sheet, row, headerCols, sec.Name)
+		row++
+		startRow := row
+
+		for _, acc := range accounts {
+			bal := layout.Sign.balance(accountBalance[acc.ID])
+			xlsxAccountMonths(xlsx, sheet, row, acc.ID, acc.Description, doc.Starts, doc.Ends, bal, prevResults[acc.ID], withPrevious)
+			row++
 		}
 
-		if newSec == -1 {
-			continue
+		for _, sum := range layout.Subtotals {
+			if slices.Contains(sum.Sections, sec.Name) {
+				summarySumRows[sum.Name] = append(summarySumRows[sum.Name], row)
+			}
 		}
 
-		if newSec != sec {
-			if sec != -1 {
-				for _, sum := range summaries {
-					if slices.Contains(sum.sectionIdxs, sec) {
-						summarySumRows[sum.name] = append(summarySumRows[sum.name], row)
-					}
-				}
+		xlsxSumMonths(xlsx, sheet, row, sec.Total, doc.Starts, doc.Ends, startRow, withPrevious)
+		sumRows = append(sumRows, row)
+		row++
 
-				xlsxSumMonths(xlsx, sheet, row, "", doc.Starts, doc.Ends, startRow)
-				sumRows = append(sumRows, row)
+		for _, sum := range layout.Subtotals {
+			if sum.After == sec.Name {
+				row++
+				xlsxSectionSum(xlsx, sheet, row, sum.Name, doc.Starts, doc.Ends, summarySumRows[sum.Name], withPrevious)
 				row++
-
-				for _, sum := range summaries {
-					if sum.afterIdx == sec {
-						row++
-						xlsxSectionSum(xlsx, sheet, row, sum.name, doc.Starts, doc.Ends, summarySumRows[sum.name])
-						row++
-					}
-				}
 			}
-
-			row++
-			xlsxHeader(xlsx, sheet, row, numMonths, sections[newSec].name)
-			row++
-			startRow = row
-			sec = newSec
-		}
-
-		if newSec == -1 {
-			continue
 		}
+	}
 
-		xlsxAccountMonths(xlsx, sheet, row, acc.ID, acc.Description, doc.Starts, doc.Ends, bal)
+	if len(sumRows) > 0 {
+		row++
+		xlsxSumSumMonths(xlsx, sheet, row, doc.Starts, doc.Ends, sumRows, withCapital, withPrevious, accountBalance, layout, inCapital)
+		row++
 		row++
 	}
 
-	xlsxSumMonths(xlsx, sheet, row, "", doc.Starts, doc.Ends, startRow)
-	sumRows = append(sumRows, row)
-	row++
-	row++
-	xlsxSumSumMonths(xlsx, sheet, row, doc.Starts, doc.Ends, sumRows, withCapital, accountBalance, inCapital)
-	row++
-	row++
-
 	style, _ = xlsx.NewStyle(nil)
 	_ = xlsx.SetCellStyle(sheet, cell('A', row+5), cell('A'+rune(numMonths)+5, 1000), style)
 }
@@ -235,7 +214,7 @@ func cell(col rune, row int) string {
 	return fmt.Sprintf("%c%d", col, row)
 }
 
-func xlsxAccountMonths(xlsx *excelize.File, sheet string, row int, id int, descr string, starts, ends time.Time, bal *balance) {
+func xlsxAccountMonths(xlsx *excelize.File, sheet string, row int, id int, descr string, starts, ends time.Time, bal *balance, prev sie.Decimal, withPrevious bool) {
 	_ = xlsx.SetCellInt(sheet, cell('A', row), id)
 	_ = xlsx.SetCellValue(sheet, cell('B', row), descr)
 	t := starts
@@ -246,6 +225,15 @@ func xlsxAccountMonths(xlsx *excelize.File, sheet string, row int, id int, descr
 		} else if len(v) != 0 {
 			_ = xlsx.SetCellFormula(sheet, cell(col, row), sumFormula(v))
 		}
+		if comment := cellComment(bal.months[t.Format("2006-01")]); comment != "" {
+			_ = xlsx.AddComment(sheet, excelize.Comment{
+				Author: "kastelo.dev/sie",
+				Cell:   cell(col, row),
+				Text:   comment,
+				Width:  300,
+				Height: uint(15*(strings.Count(comment, "\n")+1) + 10),
+			})
+		}
 		if style := cellStyle(bal.months[t.Format("2006-01")]); style != nil {
 			style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), customNumberFormat(), style))
 			_ = xlsx.SetCellStyle(sheet, cell(col, row), cell(col, row), style)
@@ -261,6 +249,14 @@ func xlsxAccountMonths(xlsx *excelize.File, sheet string, row int, id int, descr
 	_ = xlsx.SetCellFormula(sheet, cell(col, row), fmt.Sprintf("SUM(C%d:%c%d)", row, col-1, row))
 	style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontItalic(), customNumberFormat()))
 	_ = xlsx.SetCellStyle(sheet, cell(col, row), cell(col, row), style)
+
+	if withPrevious {
+		col++
+		if prev != 0 {
+			_ = xlsx.SetCellValue(sheet, cell(col, row), prev.Float64())
+		}
+		_ = xlsx.SetCellStyle(sheet, cell(col, row), cell(col, row), style)
+	}
 }
 
 func defaultStyle() *excelize.Style {
@@ -366,7 +362,7 @@ func mergeStyles(ext ...*excelize.Style) *excelize.Style {
 	return ext[0]
 }
 
-func xlsxHeaderMonths(xlsx *excelize.File, sheet string, row int, hdr string, starts, ends time.Time) {
+func xlsxHeaderMonths(xlsx *excelize.File, sheet string, row int, hdr string, starts, ends time.Time, withPrevious bool) {
 	_ = xlsx.SetCellValue(sheet, cell('B', row), hdr)
 	t := starts
 	col := 'C'
@@ -378,6 +374,10 @@ func xlsxHeaderMonths(xlsx *excelize.File, sheet string, row int, hdr string, st
 	col++
 
 	_ = xlsx.SetCellValue(sheet, cell(col, row), "Total")
+	if withPrevious {
+		col++
+		_ = xlsx.SetCellValue(sheet, cell(col, row), "Föreg. år")
+	}
 
 	style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), textAlignment("right")))
 	_ = xlsx.SetCellStyle(sheet, cell('B', row), cell(col, row), style)
@@ -389,7 +389,7 @@ func xlsxHeader(xlsx *excelize.File, sheet string, row, cols int, hdr string) {
 	_ = xlsx.SetCellStyle(sheet, cell('B', row), cell('B'+rune(cols)+2, row), style)
 }
 
-func xlsxSumMonths(xlsx *excelize.File, sheet string, row int, hdr string, starts, ends time.Time, startRow int) {
+func xlsxSumMonths(xlsx *excelize.File, sheet string, row int, hdr string, starts, ends time.Time, startRow int, withPrevious bool) {
 	_ = xlsx.SetCellValue(sheet, cell('B', row), hdr)
 	t := starts
 	col := 'C'
@@ -401,12 +401,17 @@ func xlsxSumMonths(xlsx *excelize.File, sheet string, row int, hdr string, start
 	col++
 
 	_ = xlsx.SetCellFormula(sheet, cell(col, row), fmt.Sprintf("SUM(%c%d:%c%d)", col, startRow, col, row-1))
+	lcol := col
+	if withPrevious {
+		lcol++
+		_ = xlsx.SetCellFormula(sheet, cell(lcol, row), fmt.Sprintf("SUM(%c%d:%c%d)", lcol, startRow, lcol, row-1))
+	}
 
 	style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), customNumberFormat(), thickBorder("top")))
 	_ = xlsx.SetCellStyle(sheet, cell('B', row), cell(col-1, row), style)
 
 	style, _ = xlsx.NewStyle(mergeStyles(defaultStyle(), fontBoldItalic(), customNumberFormat(), thickBorder("top")))
-	_ = xlsx.SetCellStyle(sheet, cell(col, row), cell(col, row), style)
+	_ = xlsx.SetCellStyle(sheet, cell(col, row), cell(lcol, row), style)
 }
 
 func sumcells(col rune, rows []int) string {
@@ -418,7 +423,7 @@ func sumcells(col rune, rows []int) string {
 	return b.String()
 }
 
-func xlsxSumSumMonths(xlsx *excelize.File, sheet string, row int, starts, ends time.Time, sumRows []int, withCapital bool, accountBalances map[int]*balance, inCapital sie.Decimal) {
+func xlsxSumSumMonths(xlsx *excelize.File, sheet string, row int, starts, ends time.Time, sumRows []int, withCapital, withPrevious bool, accountBalances map[int]*balance, layout *ResultLayout, inCapital sie.Decimal) {
 	_ = xlsx.SetCellValue(sheet, cell('B', row), "Resultat")
 
 	// sum
@@ -433,11 +438,15 @@ func xlsxSumSumMonths(xlsx *excelize.File, sheet string, row int, starts, ends t
 	col++
 	_ = xlsx.SetCellFormula(sheet, cell(col, row), sumcells(col, sumRows))
 	ecol := col
+	if withPrevious {
+		ecol++
+		_ = xlsx.SetCellFormula(sheet, cell(ecol, row), sumcells(ecol, sumRows))
+	}
 
 	style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), fontBold(), customNumberFormat(), thickBorder("top")))
-	_ = xlsx.SetCellStyle(sheet, cell('B', row), cell(ecol-1, row), style)
+	_ = xlsx.SetCellStyle(sheet, cell('B', row), cell(col-1, row), style)
 	style, _ = xlsx.NewStyle(mergeStyles(defaultStyle(), fontBoldItalic(), customNumberFormat(), thickBorder("top")))
-	_ = xlsx.SetCellStyle(sheet, cell(ecol, row), cell(ecol, row), style)
+	_ = xlsx.SetCellStyle(sheet, cell(col, row), cell(ecol, row), style)
 	resultRow := row
 
 	// quarterly sums
@@ -475,13 +484,13 @@ func xlsxSumSumMonths(xlsx *excelize.File, sheet string, row int, starts, ends t
 		scol := 'C'
 		for t = starts; !t.After(ends); t = t.AddDate(0, 1, 0) {
 			capital := inCapital
-			for _, acc := range currentCapitalAccounts {
+			for _, acc := range layout.CapitalAccounts {
 				bal := accountBalances[acc]
 				if bal == nil {
 					continue
 				}
 				for _, cv := range bal.months[t.Format("2006-01")] {
-					capital -= cv.amount
+					capital += layout.Sign.apply(cv.amount)
 				}
 			}
 
@@ -502,7 +511,7 @@ func xlsxSumSumMonths(xlsx *excelize.File, sheet string, row int, starts, ends t
 	}
 }
 
-func xlsxSectionSum(xlsx *excelize.File, sheet string, row int, hdr string, starts, ends time.Time, sumRows []int) {
+func xlsxSectionSum(xlsx *excelize.File, sheet string, row int, hdr string, starts, ends time.Time, sumRows []int, withPrevious bool) {
 	_ = xlsx.SetCellValue(sheet, cell('B', row), hdr)
 
 	// sum
@@ -517,14 +526,133 @@ func xlsxSectionSum(xlsx *excelize.File, sheet string, row int, hdr string, star
 	col++
 	_ = xlsx.SetCellFormula(sheet, cell(col, row), sumcells(col, sumRows))
 	ecol := col
+	if withPrevious {
+		ecol++
+		_ = xlsx.SetCellFormula(sheet, cell(ecol, row), sumcells(ecol, sumRows))
+	}
 
 	style, _ := xlsx.NewStyle(mergeStyles(defaultStyle(), verticalCenter(), fontBold(), customNumberFormat(), thickBorder("top", "bottom")))
-	_ = xlsx.SetCellStyle(sheet, cell('B', row), cell(ecol-1, row), style)
+	_ = xlsx.SetCellStyle(sheet, cell('B', row), cell(col-1, row), style)
 	style, _ = xlsx.NewStyle(mergeStyles(defaultStyle(), verticalCenter(), fontBoldItalic(), customNumberFormat(), thickBorder("top", "bottom")))
-	_ = xlsx.SetCellStyle(sheet, cell(ecol, row), cell(ecol, row), style)
+	_ = xlsx.SetCellStyle(sheet, cell(col, row), cell(ecol, row), style)
 	_ = xlsx.SetRowHeight(sheet, row, 20)
 }
 
+// annotationsByDimension returns the document's objects ordered by
+// dimension, with subdimensions directly after their parent dimension.
+func annotationsByDimension(doc *sie.Document) []sie.Annotation {
+	anns := slices.Clone(doc.Annotations)
+	slices.SortStableFunc(anns, func(a, b sie.Annotation) int {
+		return slices.Compare(dimensionPath(doc, a.Tag), dimensionPath(doc, b.Tag))
+	})
+	return anns
+}
+
+// dimensionPath returns the dimension numbers from the top level
+// dimension down to the given one.
+func dimensionPath(doc *sie.Document, id int) []int {
+	path := []int{id}
+	for len(path) < 10 {
+		dim, ok := doc.Dimension(path[0])
+		if !ok || dim.Parent == 0 {
+			break
+		}
+		path = append([]int{dim.Parent}, path...)
+	}
+	return path
+}
+
+// sheetName returns the name of the sheet for an object, such as
+// "Projekt - FOO".
+func sheetName(doc *sie.Document, ann sie.Annotation) string {
+	dimName := fmt.Sprintf("Dim %d", ann.Tag)
+	if dim, ok := doc.Dimension(ann.Tag); ok {
+		dimName = dim.Name
+	}
+	objName := ann.Description
+	if objName == "" {
+		objName = ann.Text
+	}
+	name := dimName + " - " + objName
+
+	// Excel limits sheet names to 31 characters and disallows some
+	// characters
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:\/?*[]`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	return name
+}
+
+// setPageHeaders sets the printed page header of each sheet to the
+// company name, organisation number and address, and the footer to the
+// fiscal year and page number.
+func setPageHeaders(xlsx *excelize.File, doc *sie.Document) {
+	left := `&"-,Bold"` + headerText(doc.CompanyName) + `&"-,Regular"`
+	if doc.OrgNo != "" {
+		left += "\n" + headerText(doc.OrgNo)
+	}
+	var right []string
+	co := doc.Company
+	for _, s := range []string{co.Contact, co.Address, co.PostalAddress, co.Phone} {
+		if s != "" {
+			right = append(right, headerText(s))
+		}
+	}
+	footer := fmt.Sprintf("Räkenskapsår %s – %s", doc.Starts.Format("2006-01-02"), doc.Ends.Format("2006-01-02"))
+	if co.Currency != "" {
+		footer += ", belopp i " + headerText(co.Currency)
+	}
+
+	opts := &excelize.HeaderFooterOptions{
+		OddHeader: "&L" + left + "&C&A&R" + strings.Join(right, "\n"),
+		OddFooter: "&L" + footer + "&RSida &P av &N",
+	}
+	for _, sheet := range xlsx.GetSheetList() {
+		// Fails only when the text is too long for Excel, in which case
+		// the sheet goes without
+		_ = xlsx.SetHeaderFooter(sheet, opts)
+	}
+}
+
+// headerText escapes s for use in a page header or footer.
+func headerText(s string) string {
+	return strings.ReplaceAll(s, "&", "&&")
+}
+
+// hasActivity returns true if the document has vouchers or, for files
+// without vouchers, period balances for the current year.
+func hasActivity(doc *sie.Document) bool {
+	if len(doc.Entries) > 0 {
+		return true
+	}
+	for _, acc := range doc.Accounts {
+		for _, pb := range acc.Periods {
+			if pb.Year == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// previousResults returns the previous fiscal year's result per account,
+// with the sign convention of the result sheet.
+func previousResults(doc *sie.Document, sign Sign) map[int]sie.Decimal {
+	res := make(map[int]sie.Decimal)
+	for _, acc := range doc.Accounts {
+		if r := acc.Balance(-1).Result; r != 0 {
+			res[acc.ID] = sign.apply(r)
+		}
+	}
+	return res
+}
+
 func sumFormula(v []cellValue) string {
 	var b strings.Builder
 	for i, d := range v {
@@ -540,6 +668,22 @@ func sumFormula(v []cellValue) string {
 	return b.String()
 }
 
+// cellComment returns a listing of the transactions behind a cell, one per
+// line.
+func cellComment(v []cellValue) string {
+	var b strings.Builder
+	for _, d := range v {
+		if d.text == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s %s: %s", d.date.Format("2006-01-02"), d.text, d.amount.FloatString(2))
+	}
+	return b.String()
+}
+
 func cellStyle(v []cellValue) *excelize.Style {
 	if len(v) == 0 {
 		return nil
@@ -0,0 +1,165 @@
+package sie
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ImportBuilder builds a SIE 4I file, for loading entries produced
+// elsewhere into an accounting system. Import files carry entries only; no
+// balances. The series and number of an entry may be left empty for the
+// receiving system to assign.
+//
+//	b := sie.NewImportBuilder("556677-8899", "Företaget AB")
+//	b.Entry("", "", date, "Lön januari").
+//		Add(7210, sie.Decimal(3500000)).
+//		Add(1930, sie.Decimal(-3500000))
+//	err := b.Write(w, sie.WriteOptions{})
+type ImportBuilder struct {
+	doc     Document
+	entries []*Entry
+}
+
+// ImportError is returned by ImportBuilder.Write when the file breaks the
+// rules for import files.
+type ImportError struct {
+	Problems []Problem
+}
+
+func (e *ImportError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.String()
+	}
+	return "invalid import file: " + strings.Join(msgs, "; ")
+}
+
+func NewImportBuilder(orgNo, companyName string) *ImportBuilder {
+	return &ImportBuilder{
+		doc: Document{
+			Type:        "4I",
+			OrgNo:       orgNo,
+			CompanyName: companyName,
+		},
+	}
+}
+
+// Account declares an account. Declaring accounts is optional; if any are
+// declared, all accounts used in the entries must be.
+func (b *ImportBuilder) Account(id int, description string) *ImportBuilder {
+	b.doc.Accounts = append(b.doc.Accounts, Account{ID: id, Description: description})
+	return b
+}
+
+// Object declares an object in a dimension.
+func (b *ImportBuilder) Object(tag int, text, description string) *ImportBuilder {
+	b.doc.Annotations = append(b.doc.Annotations, Annotation{Tag: tag, Text: text, Description: description})
+	return b
+}
+
+// Entry adds an entry and returns it, for adding transactions.
+func (b *ImportBuilder) Entry(series, id string, date time.Time, text string) *Entry {
+	e := &Entry{
+		ID:          id,
+		Type:        series,
+		Date:        date,
+		Description: text,
+	}
+	b.entries = append(b.entries, e)
+	return e
+}
+
+// Document returns the import file as a Document.
+func (b *ImportBuilder) Document() *Document {
+	doc := b.doc
+	doc.Entries = make([]Entry, len(b.entries))
+	for i, e := range b.entries {
+		doc.Entries[i] = *e
+	}
+	return &doc
+}
+
+// Validate returns the problems that would prevent writing the file.
+func (b *ImportBuilder) Validate() []Problem {
+	return ValidateImport(b.Document())
+}
+
+// Write validates and writes the import file. If the file breaks the
+// rules for import files the error is an *ImportError and nothing is
+// written.
+func (b *ImportBuilder) Write(w io.Writer, opts WriteOptions) error {
+	doc := b.Document()
+	if problems := ValidateImport(doc); len(problems) > 0 {
+		return &ImportError{Problems: problems}
+	}
+	return WriteWithOptions(w, doc, opts)
+}
+
+// Add adds a transaction to the entry, with the date and text of the
+// entry.
+func (e *Entry) Add(accountID int, amount Decimal, annotations ...Annotation) *Entry {
+	e.Transactions = append(e.Transactions, Transaction{
+		AccountID:   accountID,
+		Amount:      amount,
+		Annotations: annotations,
+		Date:        e.Date,
+		Text:        e.Description,
+		Sign:        e.Sign,
+	})
+	return e
+}
+
+// ValidateImport checks the document against the rules for SIE 4I import
+// files: there may be no balances, every entry must have a date and
+// balancing transactions without corrections, and if accounts are declared
+// the entries may only use those.
+func ValidateImport(doc *Document) []Problem {
+	var problems []Problem
+	problem := func(e *Entry, accID int, format string, args ...any) {
+		p := Problem{AccountID: accID, Severity: SeverityError, Message: fmt.Sprintf(format, args...)}
+		if e != nil {
+			p.Line, p.Series, p.EntryID = e.Line, e.Type, e.ID
+		}
+		problems = append(problems, p)
+	}
+
+	if doc.Type != "" && doc.Type != "4I" {
+		problem(nil, 0, "file type is %s, expected 4I", doc.Type)
+	}
+
+	declared := make(map[int]bool, len(doc.Accounts))
+	for _, acc := range doc.Accounts {
+		declared[acc.ID] = true
+		if acc.InBalance != 0 || acc.OutBalance != 0 || acc.Result != 0 || len(acc.Previous) > 0 ||
+			len(acc.ObjectBalances) > 0 || len(acc.Periods) > 0 || len(acc.Budgets) > 0 {
+			problem(nil, acc.ID, "account %d has balances, which import files can't carry", acc.ID)
+		}
+	}
+
+	for i := range doc.Entries {
+		e := &doc.Entries[i]
+		if e.Date.IsZero() {
+			problem(e, 0, "entry has no date")
+		}
+		if len(e.Transactions) == 0 {
+			problem(e, 0, "entry has no transactions")
+		}
+		var sum Decimal
+		for _, t := range e.Transactions {
+			if t.Kind != TransactionNormal {
+				problem(e, t.AccountID, "import files can't carry corrections (#RTRANS, #BTRANS)")
+			}
+			if len(declared) > 0 && !declared[t.AccountID] {
+				problem(e, t.AccountID, "account %d is not declared", t.AccountID)
+			}
+			sum += t.Amount
+		}
+		if sum != 0 {
+			problem(e, 0, "entry does not balance, transactions sum to %s", sum.FloatString(2))
+		}
+	}
+
+	return problems
+}
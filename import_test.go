@@ -0,0 +1,89 @@
+package sie
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportBuilder(t *testing.T) {
+	date := time.Date(2026, 1, 25, 0, 0, 0, 0, time.UTC)
+	b := NewImportBuilder("556677-8899", "Company AB")
+	b.Entry("", "", date, "Salary January").
+		Add(7210, 3500000, Annotation{Tag: 1, Text: "10"}).
+		Add(2710, -1000000).
+		Add(1930, -2500000)
+	b.Entry("L", "", date, "Payroll tax").
+		Add(7510, 1099700).
+		Add(2731, -1099700)
+
+	var buf bytes.Buffer
+	if err := b.Write(&buf, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{"#SIETYP 4I\r\n", "#VER \"\" \"\" 20260125 \"Salary January\"\r\n", "#VER L \"\" 20260125 \"Payroll tax\"\r\n"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected %q in output", line)
+		}
+	}
+	for _, label := range []string{"#RAR", "#IB", "#UB", "#RES"} {
+		if strings.Contains(out, label) {
+			t.Errorf("unexpected %s in import file", label)
+		}
+	}
+
+	doc, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Entries) != 2 || len(doc.Entries[0].Transactions) != 3 {
+		t.Fatalf("unexpected entries %+v", doc.Entries)
+	}
+	if problems := ValidateImport(doc); len(problems) != 0 {
+		t.Errorf("unexpected problems %v", problems)
+	}
+}
+
+func TestImportBuilderValidation(t *testing.T) {
+	b := NewImportBuilder("556677-8899", "Company AB")
+	b.Account(1930, "Bank").Account(3010, "Sales")
+	b.Entry("A", "1", time.Date(2026, 1, 25, 0, 0, 0, 0, time.UTC), "Sale").
+		Add(1930, 10000).
+		Add(3011, -9000)
+	b.Entry("A", "2", time.Time{}, "Nothing")
+
+	expected := []string{
+		"A1: error: account 3011 is not declared",
+		"A1: error: entry does not balance, transactions sum to 10.00",
+		"A2: error: entry has no date",
+		"A2: error: entry has no transactions",
+	}
+	problems := b.Validate()
+	if len(problems) != len(expected) {
+		t.Fatalf("unexpected problems %v", problems)
+	}
+	for i, p := range problems {
+		if p.String() != expected[i] {
+			t.Errorf("got problem %q, expected %q", p, expected[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	err := b.Write(&buf, WriteOptions{})
+	var impErr *ImportError
+	if !errors.As(err, &impErr) || len(impErr.Problems) != len(expected) {
+		t.Fatal("expected import error, got", err)
+	}
+	if buf.Len() != 0 {
+		t.Error("nothing should be written for an invalid file")
+	}
+
+	// A complete export is not an import file
+	doc := &Document{Type: "4", Accounts: []Account{{ID: 1930, OutBalance: 100}}}
+	if problems := ValidateImport(doc); len(problems) != 2 {
+		t.Errorf("unexpected problems %v", problems)
+	}
+}
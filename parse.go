@@ -2,173 +2,107 @@ package sie
 
 import (
 	"bufio"
+	"bytes"
 	"cmp"
+	"errors"
 	"fmt"
 	"io"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"golang.org/x/text/encoding/charmap"
 )
 
-func Parse(r io.Reader) (*Document, error) {
-	r = charmap.CodePage437.NewDecoder().Reader(r)
+// ParseOptions controls the behaviour of ParseWithOptions. The zero value
+// gives the same behaviour as Parse.
+type ParseOptions struct {
+	// IgnoreChecksum skips verification of the #KSUMMA checksum.
+	IgnoreChecksum bool
 
-	var doc Document
-	var curVer Entry
-	accountCache := make(map[int]int)
+	// Lenient skips records that cannot be parsed, collecting the errors
+	// in Document.Warnings, instead of failing on the first one.
+	Lenient bool
 
-	sc := bufio.NewScanner(r)
-	for sc.Scan() {
-		words := splitWords(sc.Text())
-		if len(words) < 1 {
-			continue
-		}
+	// Charset is the character encoding of the file. The default is code
+	// page 437, as required by the standard.
+	Charset Charset
 
-		switch words[0] {
-		case "#PROGRAM":
-			doc.ProgramName = words[1]
-			doc.ProgramVersion = words[2]
-
-		case "#GEN":
-			if len(words) >= 2 {
-				date, err := time.Parse("20060102", words[1])
-				if err != nil {
-					return nil, err
-				}
-				doc.GeneratedAt = date
-			}
-			if len(words) >= 3 {
-				doc.GeneratedBy = words[2]
-			}
+	// KeepOrder keeps accounts, entries, dimensions and objects in file
+	// order instead of sorting them.
+	KeepOrder bool
 
-		case "#SIETYP":
-			doc.Type = words[1]
+	// DiscardUnknown drops records with labels the parser doesn't handle,
+	// instead of keeping them in Document.Unknown and Entry.Unknown.
+	DiscardUnknown bool
 
-		case "#ORGNR":
-			doc.OrgNo = words[1]
+	// From and To limit the entries kept to those dated within the range,
+	// inclusive. A zero time leaves that end of the range open.
+	From, To time.Time
+}
 
-		case "#FNAMN":
-			doc.CompanyName = words[1]
+// Charset is the character encoding of a SIE file.
+type Charset int
 
-		case "#RAR":
-			if words[1] == "0" {
-				// Current fiscal year
-				doc.Starts, _ = time.Parse("20060102", words[2])
-				doc.Ends, _ = time.Parse("20060102", words[3])
-			}
-
-		case "#KPTYP":
-			doc.AccountPlan = words[1]
+const (
+	// CharsetPC8 is IBM code page 437, which the standard calls PC8.
+	CharsetPC8 Charset = iota
+	CharsetUTF8
+	CharsetWindows1252
+	// CharsetAuto detects the encoding from the start of the file, for
+	// files that claim to be PC8 but aren't.
+	CharsetAuto
+)
 
-		case "#KONTO":
-			acc := Account{
-				ID:          tryParseInt(words[1]),
-				Description: words[2],
-			}
-			accountCache[acc.ID] = len(doc.Accounts)
-			doc.Accounts = append(doc.Accounts, acc)
-
-		case "#KTYP":
-			accID := tryParseInt(words[1])
-			idx, ok := accountCache[accID]
-			if !ok {
-				return nil, fmt.Errorf("unknown account %q", words[1])
-			}
-			doc.Accounts[idx].Type = words[2]
+// The number of bytes looked at when detecting the character encoding
+const detectSize = 64 << 10
 
-		case "#IB":
-			if words[1] != "0" {
-				continue
-			}
-			amount, err := ParseDecimal(words[3])
-			if err != nil {
-				return nil, err
-			}
-			accID := tryParseInt(words[2])
-			idx, ok := accountCache[accID]
-			if !ok {
-				return nil, fmt.Errorf("unknown account %q", words[2])
-			}
-			doc.Accounts[idx].InBalance = amount
+// ParseError is an error in a specific record of the file.
+type ParseError struct {
+	Line  int    // line number, counting from one
+	Label string // record label, such as "#VER"
+	Raw   string // the line as read
+	Err   error
+}
 
-		case "#UB":
-			if words[1] != "0" {
-				continue
-			}
-			amount, err := ParseDecimal(words[3])
-			if err != nil {
-				return nil, err
-			}
-			accID := tryParseInt(words[2])
-			idx, ok := accountCache[accID]
-			if !ok {
-				return nil, fmt.Errorf("unknown account %q", words[2])
-			}
-			doc.Accounts[idx].OutBalance = amount
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s: %v", e.Line, e.Label, e.Err)
+}
 
-		case "#VER":
-			date, err := time.Parse("20060102", words[3])
-			if err != nil {
-				return nil, err
-			}
-			filed, err := time.Parse("20060102", words[5])
-			if err != nil {
-				return nil, err
-			}
-			curVer = Entry{
-				ID:          words[2],
-				Type:        words[1],
-				Date:        date,
-				Description: words[4],
-				Filed:       filed,
-			}
-			if doc.Starts.IsZero() || doc.Starts.After(date) {
-				doc.Starts = date
-			}
-			if doc.Ends.IsZero() || doc.Ends.Before(date) {
-				doc.Ends = date
-			}
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
 
-		case "#TRANS":
-			var annotations []Annotation
-			if words[2] != "" {
-				// There's an annotation
-				parts := strings.Split(words[2], " ")
-				if len(parts)%2 != 0 {
-					return nil, fmt.Errorf("annotation has odd number of parts")
-				}
-				for i := 0; i < len(parts); i += 2 {
-					tagNo, _ := strconv.Atoi(maybeUnquote(parts[i]))
-					text := maybeUnquote(parts[i+1])
-					annotations = append(annotations, Annotation{Tag: tagNo, Text: text})
-				}
-			}
-			amount, err := ParseDecimal(words[3])
-			if err != nil {
-				return nil, err
-			}
-			accID := tryParseInt(words[1])
-			trans := Transaction{
-				AccountID:   accID,
-				Amount:      amount,
-				Annotations: annotations,
-			}
-			curVer.Transactions = append(curVer.Transactions, trans)
+func Parse(r io.Reader) (*Document, error) {
+	return ParseWithOptions(r, ParseOptions{})
+}
 
-		case "#OBJEKT":
-			tag, _ := strconv.Atoi(words[1])
-			text := words[2]
-			description := words[3]
-			doc.Annotations = append(doc.Annotations, Annotation{Tag: tag, Text: text, Description: description})
+func ParseWithOptions(r io.Reader, opts ParseOptions) (*Document, error) {
+	p := newParser(opts)
 
-		case "}":
-			doc.Entries = append(doc.Entries, curVer)
+	sc := bufio.NewScanner(decoder(r, opts.Charset))
+	for sc.Scan() {
+		if err := p.parseLine(sc.Text()); err != nil {
+			return nil, err
 		}
 	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := p.finish(); err != nil {
+		return nil, err
+	}
 
+	if !opts.KeepOrder {
+		sortDocument(&p.doc)
+	}
+	return &p.doc, nil
+}
+
+// sortDocument sorts the accounts, entries, dimensions and objects.
+func sortDocument(doc *Document) {
 	slices.SortFunc(doc.Accounts, func(a, b Account) int {
 		return cmp.Compare(a.ID, b.ID)
 	})
@@ -178,14 +112,703 @@ func Parse(r io.Reader) (*Document, error) {
 		}
 		return cmp.Compare(a.ID, b.ID)
 	})
+	slices.SortFunc(doc.Dimensions, func(a, b Dimension) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
 	slices.SortFunc(doc.Annotations, func(a, b Annotation) int {
 		if d := cmp.Compare(a.Tag, b.Tag); d != 0 {
 			return d
 		}
 		return cmp.Compare(a.String(), b.String())
 	})
+}
+
+type parser struct {
+	opts         ParseOptions
+	doc          Document
+	accountCache map[int]int
+
+	line      int
+	prevLabel string
+
+	curVer Entry
+	inVer  bool
+
+	// The checksum covers the records between the opening #KSUMMA and
+	// the closing one that carries the checksum
+	sum          *checksum
+	checksumDone bool
+
+	// emit, when set, receives completed entries instead of them being
+	// added to the document
+	emit func(Entry)
+}
+
+func newParser(opts ParseOptions) *parser {
+	return &parser{
+		opts:         opts,
+		accountCache: make(map[int]int),
+	}
+}
+
+// parseLine parses the next line of the file. The returned error is a
+// *ParseError, or nil in lenient mode.
+func (p *parser) parseLine(raw string) error {
+	p.line++
+	if p.line == 1 {
+		raw = strings.TrimPrefix(raw, "\ufeff")
+	}
+	words := splitWords(raw)
+	if len(words) < 1 {
+		return nil
+	}
+	err := p.record(words, raw)
+	p.prevLabel = words[0]
+	if err != nil {
+		return p.fail(&ParseError{Line: p.line, Label: words[0], Raw: raw, Err: err})
+	}
+	return nil
+}
+
+// finish checks for an unterminated entry or checksum at the end of the
+// file.
+func (p *parser) finish() error {
+	if p.inVer {
+		err := &ParseError{Line: p.curVer.Line, Label: "#VER", Err: errors.New("entry is not terminated")}
+		if err := p.fail(err); err != nil {
+			return err
+		}
+	}
+	if p.sum != nil {
+		err := &ParseError{Line: p.line, Label: "#KSUMMA", Err: ErrChecksumMissing}
+		if err := p.fail(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fail returns the error, or in lenient mode records it as a warning and
+// returns nil.
+func (p *parser) fail(err *ParseError) error {
+	if !p.opts.Lenient {
+		return err
+	}
+	p.doc.Warnings = append(p.doc.Warnings, err)
+	return nil
+}
+
+func (p *parser) record(words []string, raw string) error {
+	doc := &p.doc
+
+	if words[0] == "#KSUMMA" {
+		return p.checksum(words)
+	}
+	if p.sum != nil {
+		p.sum.addRecord(raw)
+	}
+
+	switch words[0] {
+	case "#PROGRAM":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		doc.ProgramName = words[1]
+		doc.ProgramVersion = optional(words, 2)
+
+	case "#GEN":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		date, err := parseDate(words[1])
+		if err != nil {
+			return err
+		}
+		doc.GeneratedAt = date
+		doc.GeneratedBy = optional(words, 2)
+
+	case "#SIETYP":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		doc.Type = words[1]
+
+	case "#ORGNR":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		doc.OrgNo = words[1]
+
+	case "#FNAMN":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		doc.CompanyName = words[1]
+
+	case "#ADRESS":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		doc.Company.Contact = words[1]
+		doc.Company.Address = optional(words, 2)
+		doc.Company.PostalAddress = optional(words, 3)
+		doc.Company.Phone = optional(words, 4)
+
+	case "#BKOD":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		doc.Company.SNI = words[1]
+
+	case "#FTYP":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		doc.Company.Type = words[1]
+
+	case "#TAXAR":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		year, err := parseInt(words[1], "year")
+		if err != nil {
+			return err
+		}
+		doc.Company.TaxYear = year
+
+	case "#OMFATTN":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		date, err := parseDate(words[1])
+		if err != nil {
+			return err
+		}
+		doc.Company.BalanceDate = &date
+
+	case "#VALUTA":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		doc.Company.Currency = words[1]
+
+	case "#FNR":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		doc.Company.ID = words[1]
+
+	case "#PROSA":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		doc.Company.Notes = words[1]
+
+	case "#RAR":
+		if err := needFields(words, 3); err != nil {
+			return err
+		}
+		var year FiscalYear
+		var err error
+		if year.Index, err = parseInt(words[1], "year index"); err != nil {
+			return err
+		}
+		if year.Starts, err = parseDate(words[2]); err != nil {
+			return err
+		}
+		if year.Ends, err = parseDate(words[3]); err != nil {
+			return err
+		}
+		doc.Years = append(doc.Years, year)
+		if year.Index == 0 {
+			// Current fiscal year
+			doc.Starts = year.Starts
+			doc.Ends = year.Ends
+		}
+
+	case "#KPTYP":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		doc.AccountPlan = words[1]
+
+	case "#KONTO":
+		if err := needFields(words, 1); err != nil {
+			return err
+		}
+		id, err := parseInt(words[1], "account number")
+		if err != nil {
+			return err
+		}
+		acc := Account{
+			ID:          id,
+			Description: optional(words, 2),
+			Line:        p.line,
+		}
+		p.accountCache[acc.ID] = len(doc.Accounts)
+		doc.Accounts = append(doc.Accounts, acc)
+
+	case "#KTYP":
+		if err := needFields(words, 2); err != nil {
+			return err
+		}
+		acc, err := p.account(words[1])
+		if err != nil {
+			return err
+		}
+		acc.Type = AccountType(words[2])
+
+	case "#SRU":
+		if err := needFields(words, 2); err != nil {
+			return err
+		}
+		acc, err := p.account(words[1])
+		if err != nil {
+			return err
+		}
+		acc.SRU = words[2]
+
+	case "#IB", "#UB", "#RES":
+		if err := needFields(words, 3); err != nil {
+			return err
+		}
+		year, err := parseInt(words[1], "year index")
+		if err != nil {
+			return err
+		}
+		acc, err := p.account(words[2])
+		if err != nil {
+			return err
+		}
+		amount, err := ParseDecimal(words[3])
+		if err != nil {
+			return err
+		}
+		acc.setBalance(year, func(b *Balance) {
+			switch words[0] {
+			case "#IB":
+				b.In = amount
+			case "#UB":
+				b.Out = amount
+			case "#RES":
+				b.Result = amount
+			}
+		})
+
+	case "#OIB", "#OUB":
+		if err := needFields(words, 4); err != nil {
+			return err
+		}
+		year, err := parseInt(words[1], "year index")
+		if err != nil {
+			return err
+		}
+		acc, err := p.account(words[2])
+		if err != nil {
+			return err
+		}
+		annotations, err := parseObjectList(words[3])
+		if err != nil {
+			return err
+		}
+		if len(annotations) == 0 {
+			return errors.New("object balance without object")
+		}
+		if len(annotations) > 1 {
+			// The balance is for one object; it can't be split between
+			// several
+			return errors.New("object balance with more than one object")
+		}
+		amount, err := ParseDecimal(words[4])
+		if err != nil {
+			return err
+		}
+		var quantity Quantity
+		if q := optional(words, 5); q != "" {
+			if quantity, err = ParseQuantity(q); err != nil {
+				return err
+			}
+		}
+		ob := acc.objectBalance(year, annotations[0])
+		if words[0] == "#OIB" {
+			ob.In, ob.InQuantity = amount, quantity
+		} else {
+			ob.Out, ob.OutQuantity = amount, quantity
+		}
+
+	case "#PSALDO", "#PBUDGET":
+		if err := needFields(words, 5); err != nil {
+			return err
+		}
+		year, err := parseInt(words[1], "year index")
+		if err != nil {
+			return err
+		}
+		period, err := time.Parse("200601", words[2])
+		if err != nil {
+			return fmt.Errorf("invalid period %q", words[2])
+		}
+		acc, err := p.account(words[3])
+		if err != nil {
+			return err
+		}
+		annotations, err := parseObjectList(words[4])
+		if err != nil {
+			return err
+		}
+		amount, err := ParseDecimal(words[5])
+		if err != nil {
+			return err
+		}
+		pb := PeriodBalance{
+			Year:        year,
+			Period:      period,
+			Annotations: annotations,
+			Amount:      amount,
+		}
+		if q := optional(words, 6); q != "" {
+			if pb.Quantity, err = ParseQuantity(q); err != nil {
+				return err
+			}
+		}
+		if words[0] == "#PSALDO" {
+			acc.Periods = append(acc.Periods, pb)
+		} else {
+			acc.Budgets = append(acc.Budgets, pb)
+		}
+
+	case "#VER":
+		wasInVer := p.inVer
+		p.inVer = false
+		if err := needFields(words, 3); err != nil {
+			return err
+		}
+		date, err := parseDate(words[3])
+		if err != nil {
+			return err
+		}
+		var filed time.Time
+		if f := optional(words, 5); f != "" {
+			if filed, err = parseDate(f); err != nil {
+				return err
+			}
+		}
+		p.curVer = Entry{
+			ID:          words[2],
+			Type:        words[1],
+			Date:        date,
+			Description: optional(words, 4),
+			Filed:       filed,
+			Sign:        optional(words, 6),
+			Line:        p.line,
+		}
+		p.inVer = true
+		if wasInVer {
+			return errors.New("previous entry is not terminated")
+		}
 
-	return &doc, nil
+	case "#TRANS", "#RTRANS", "#BTRANS":
+		if !p.inVer {
+			return errors.New("transaction outside of entry")
+		}
+		trans, err := parseTransaction(words, p.curVer)
+		if err != nil {
+			return err
+		}
+		trans.Line = p.line
+		switch words[0] {
+		case "#RTRANS":
+			trans.Kind = TransactionAdded
+		case "#BTRANS":
+			trans.Kind = TransactionRemoved
+		default:
+			// An added row is followed by an identical #TRANS for the
+			// benefit of readers that don't know #RTRANS
+			if n := len(p.curVer.Transactions); p.prevLabel == "#RTRANS" && n > 0 && sameRow(p.curVer.Transactions[n-1], trans) {
+				return nil
+			}
+		}
+		p.curVer.Transactions = append(p.curVer.Transactions, trans)
+
+	case "#DIM":
+		if err := needFields(words, 2); err != nil {
+			return err
+		}
+		id, err := parseInt(words[1], "dimension")
+		if err != nil {
+			return err
+		}
+		doc.Dimensions = append(doc.Dimensions, Dimension{
+			ID:   id,
+			Name: words[2],
+		})
+
+	case "#UNDERDIM":
+		if err := needFields(words, 3); err != nil {
+			return err
+		}
+		id, err := parseInt(words[1], "dimension")
+		if err != nil {
+			return err
+		}
+		parent, err := parseInt(words[3], "dimension")
+		if err != nil {
+			return err
+		}
+		doc.Dimensions = append(doc.Dimensions, Dimension{
+			ID:     id,
+			Name:   words[2],
+			Parent: parent,
+		})
+
+	case "#OBJEKT":
+		if err := needFields(words, 2); err != nil {
+			return err
+		}
+		tag, err := parseInt(words[1], "dimension")
+		if err != nil {
+			return err
+		}
+		doc.Annotations = append(doc.Annotations, Annotation{Tag: tag, Text: words[2], Description: optional(words, 3)})
+
+	case "}":
+		if !p.inVer {
+			return errors.New("end of entry without #VER")
+		}
+		p.inVer = false
+		date := p.curVer.Date
+		if !p.opts.From.IsZero() && date.Before(p.opts.From) || !p.opts.To.IsZero() && date.After(p.opts.To) {
+			return nil
+		}
+		if p.emit != nil {
+			p.emit(p.curVer)
+		} else {
+			doc.Entries = append(doc.Entries, p.curVer)
+		}
+		if doc.Starts.IsZero() || doc.Starts.After(date) {
+			doc.Starts = date
+		}
+		if doc.Ends.IsZero() || doc.Ends.Before(date) {
+			doc.Ends = date
+		}
+
+	case "#FLAGGA", "#FORMAT", "{":
+		// Nothing to keep; the writer produces these itself
+
+	default:
+		if p.opts.DiscardUnknown {
+			return nil
+		}
+		rec := Record{
+			Label:  words[0],
+			Fields: splitFields(raw)[1:],
+			Line:   p.line,
+			Raw:    raw,
+		}
+		if p.inVer {
+			p.curVer.Unknown = append(p.curVer.Unknown, rec)
+		} else {
+			doc.Unknown = append(doc.Unknown, rec)
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) checksum(words []string) error {
+	switch {
+	case p.opts.IgnoreChecksum || p.checksumDone:
+	case optional(words, 1) == "":
+		p.sum = newChecksum()
+	case p.sum == nil:
+		return errors.New("checksum without preceding #KSUMMA header")
+	default:
+		expected, err := strconv.ParseInt(words[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid checksum %q", words[1])
+		}
+		actual := p.sum.Sum32()
+		p.sum = nil
+		p.checksumDone = true
+		if uint32(expected) != actual {
+			return &ChecksumError{Expected: uint32(expected), Actual: actual}
+		}
+	}
+	return nil
+}
+
+// account returns the declared account with the given number.
+func (p *parser) account(s string) (*Account, error) {
+	id, err := parseInt(s, "account number")
+	if err != nil {
+		return nil, err
+	}
+	idx, ok := p.accountCache[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown account %q", s)
+	}
+	return &p.doc.Accounts[idx], nil
+}
+
+// parseTransaction parses a #TRANS, #RTRANS or #BTRANS record belonging
+// to the entry.
+func parseTransaction(words []string, curVer Entry) (Transaction, error) {
+	if err := needFields(words, 3); err != nil {
+		return Transaction{}, err
+	}
+	accID, err := parseInt(words[1], "account number")
+	if err != nil {
+		return Transaction{}, err
+	}
+	annotations, err := parseObjectList(words[2])
+	if err != nil {
+		return Transaction{}, err
+	}
+	amount, err := ParseDecimal(words[3])
+	if err != nil {
+		return Transaction{}, err
+	}
+	trans := Transaction{
+		AccountID:   accID,
+		Amount:      amount,
+		Annotations: annotations,
+		Date:        curVer.Date,
+		Text:        curVer.Description,
+		Sign:        curVer.Sign,
+	}
+	if d := optional(words, 4); d != "" {
+		if trans.Date, err = parseDate(d); err != nil {
+			return Transaction{}, err
+		}
+	}
+	if t := optional(words, 5); t != "" {
+		trans.Text = t
+	}
+	if q := optional(words, 6); q != "" {
+		if trans.Quantity, err = ParseQuantity(q); err != nil {
+			return Transaction{}, err
+		}
+	}
+	if s := optional(words, 7); s != "" {
+		trans.Sign = s
+	}
+	return trans, nil
+}
+
+// sameRow returns true if the transaction rows are identical apart from
+// their kind and position.
+func sameRow(a, b Transaction) bool {
+	return a.AccountID == b.AccountID && a.Amount == b.Amount && a.Date.Equal(b.Date) &&
+		a.Text == b.Text && a.Quantity == b.Quantity && a.Sign == b.Sign &&
+		slices.EqualFunc(a.Annotations, b.Annotations, Annotation.Equals)
+}
+
+// parseObjectList parses the contents of an object list such as
+// `1 "456" 6 "P1"` into annotations.
+func parseObjectList(s string) ([]Annotation, error) {
+	if s == "" {
+		return nil, nil
+	}
+	parts := splitWords(s)
+	if len(parts)%2 != 0 {
+		return nil, fmt.Errorf("annotation has odd number of parts")
+	}
+	var annotations []Annotation
+	for i := 0; i < len(parts); i += 2 {
+		tagNo, err := parseInt(parts[i], "dimension")
+		if err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, Annotation{Tag: tagNo, Text: parts[i+1]})
+	}
+	return annotations, nil
+}
+
+// decoder returns a reader decoding the given character set to UTF-8.
+func decoder(r io.Reader, cs Charset) io.Reader {
+	if cs == CharsetAuto {
+		br := bufio.NewReaderSize(r, detectSize)
+		head, _ := br.Peek(detectSize)
+		cs = detectCharset(head)
+		r = br
+	}
+	switch cs {
+	case CharsetUTF8:
+		return r
+	case CharsetWindows1252:
+		return charmap.Windows1252.NewDecoder().Reader(r)
+	default:
+		return charmap.CodePage437.NewDecoder().Reader(r)
+	}
+}
+
+// detectCharset guesses the character set of the file from its first
+// bytes. Files that are valid UTF-8 are taken to be so. Otherwise, the
+// Swedish letters are at 0x80-0x9f in code page 437 and at 0xc0-0xff in
+// Windows-1252, so we look at where most of the high bytes are.
+func detectCharset(head []byte) Charset {
+	if bytes.HasPrefix(head, []byte("\xef\xbb\xbf")) {
+		return CharsetUTF8
+	}
+
+	var high, latin int
+	for _, b := range head {
+		if b >= 0x80 {
+			high++
+		}
+		if b >= 0xc0 {
+			latin++
+		}
+	}
+	if high == 0 {
+		return CharsetPC8
+	}
+
+	// The peeked data may end in the middle of a rune
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	if utf8.Valid(head) {
+		return CharsetUTF8
+	}
+	if latin > high/2 {
+		return CharsetWindows1252
+	}
+	return CharsetPC8
+}
+
+// needFields returns an error unless the record has at least n fields
+// after the label.
+func needFields(words []string, n int) error {
+	if len(words)-1 < n {
+		return fmt.Errorf("expected at least %d fields, got %d", n, len(words)-1)
+	}
+	return nil
+}
+
+// optional returns the i:th word of the record, or the empty string if
+// the record is shorter than that.
+func optional(words []string, i int) string {
+	if i < len(words) {
+		return words[i]
+	}
+	return ""
+}
+
+func parseDate(s string) (time.Time, error) {
+	t, err := time.Parse("20060102", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return t, nil
+}
+
+func parseInt(s, what string) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", what, s)
+	}
+	return i, nil
 }
 
 func maybeUnquote(s string) string {
@@ -197,8 +820,3 @@ func maybeUnquote(s string) string {
 	}
 	return s
 }
-
-func tryParseInt(s string) int {
-	i, _ := strconv.Atoi(s)
-	return i
-}
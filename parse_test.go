@@ -2,7 +2,10 @@ package sie
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -21,6 +24,9 @@ func TestParse(t *testing.T) {
 		AccountPlan:    "EUBAS97",
 		Starts:         time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
 		Ends:           time.Date(2016, 12, 31, 0, 0, 0, 0, time.UTC),
+		Years: []FiscalYear{
+			{Index: 0, Starts: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), Ends: time.Date(2016, 12, 31, 0, 0, 0, 0, time.UTC)},
+		},
 		Accounts: []Account{
 			{
 				ID: 1930, Type: "T", Description: "Bankkonto",
@@ -46,8 +52,8 @@ func TestParse(t *testing.T) {
 				Filed:       time.Date(2016, 1, 3, 0, 0, 0, 0, time.UTC),
 				Description: "Aktiekapital",
 				Transactions: []Transaction{
-					{AccountID: 1930, Annotations: []Annotation{{Tag: 2, Text: "FOO"}}, Amount: 50000 * 100},
-					{AccountID: 2081, Annotations: []Annotation{{Tag: 3, Text: "BAR"}}, Amount: -50000 * 100},
+					{AccountID: 1930, Annotations: []Annotation{{Tag: 2, Text: "FOO"}}, Amount: 50000 * 100, Date: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), Text: "Aktiekapital"},
+					{AccountID: 2081, Annotations: []Annotation{{Tag: 3, Text: "BAR"}}, Amount: -50000 * 100, Date: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), Text: "Aktiekapital"},
 				},
 			}, {
 				Type:        "A",
@@ -56,8 +62,8 @@ func TestParse(t *testing.T) {
 				Filed:       time.Date(2016, 8, 30, 0, 0, 0, 0, time.UTC),
 				Description: "Försäkring F",
 				Transactions: []Transaction{
-					{AccountID: 1930, Amount: -1957 * 100},
-					{AccountID: 6310, Amount: 1957 * 100},
+					{AccountID: 1930, Amount: -1957 * 100, Date: time.Date(2016, 8, 29, 0, 0, 0, 0, time.UTC), Text: "Försäkring F"},
+					{AccountID: 6310, Amount: 1957 * 100, Date: time.Date(2016, 8, 29, 0, 0, 0, 0, time.UTC), Text: "Försäkring F"},
 				},
 			},
 		},
@@ -77,6 +83,406 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseMultipleYears(t *testing.T) {
+	const input = `#RAR 0 20160101 20161231
+#RAR -1 20150101 20151231
+#KONTO 1930 "Bankkonto"
+#KONTO 3010 "Försäljning"
+#IB 0 1930 100.00
+#UB 0 1930 250.00
+#IB -1 1930 50.00
+#UB -1 1930 100.00
+#RES 0 3010 -150.00
+#RES -1 3010 -50.00
+`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev, ok := doc.Year(-1)
+	if !ok {
+		t.Fatal("missing year -1")
+	}
+	if !prev.Starts.Equal(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected start of year -1: %v", prev.Starts)
+	}
+	if _, ok := doc.Year(-2); ok {
+		t.Error("unexpected year -2")
+	}
+
+	if bal := doc.Accounts[0].Balance(0); bal.In != 10000 || bal.Out != 25000 {
+		t.Errorf("unexpected balance for year 0: %+v", bal)
+	}
+	if bal := doc.Accounts[0].Balance(-1); bal.In != 5000 || bal.Out != 10000 {
+		t.Errorf("unexpected balance for year -1: %+v", bal)
+	}
+	if res := doc.Accounts[1].Balance(0).Result; res != -15000 {
+		t.Errorf("unexpected result for year 0: %v", res)
+	}
+	if res := doc.Accounts[1].Balance(-1).Result; res != -5000 {
+		t.Errorf("unexpected result for year -1: %v", res)
+	}
+}
+
+func TestParsePeriodBalances(t *testing.T) {
+	const input = `#RAR 0 20160101 20161231
+#KONTO 3010 "Försäljning"
+#OBJEKT 6 "P1" "Projekt ett"
+#PSALDO 0 201601 3010 {} -1000.00 -10
+#PSALDO 0 201601 3010 {6 "P1"} -400.00
+#PBUDGET 0 201601 3010 {} -900.00
+`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jan := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	expectedPeriods := []PeriodBalance{
+		{Year: 0, Period: jan, Amount: -100000, Quantity: NewQuantity(-10, 0)},
+		{Year: 0, Period: jan, Annotations: []Annotation{{Tag: 6, Text: "P1"}}, Amount: -40000},
+	}
+	expectedBudgets := []PeriodBalance{
+		{Year: 0, Period: jan, Amount: -90000},
+	}
+	if got, exp := jsons(doc.Accounts[0].Periods), jsons(expectedPeriods); got != exp {
+		t.Error(godiffpatch.GeneratePatch("periods", exp, got))
+	}
+	if got, exp := jsons(doc.Accounts[0].Budgets), jsons(expectedBudgets); got != exp {
+		t.Error(godiffpatch.GeneratePatch("budgets", exp, got))
+	}
+}
+
+func TestParseDimensions(t *testing.T) {
+	const input = `#DIM 1 "Avdelning"
+#UNDERDIM 21 "Delprojekt" 6
+#OBJEKT 21 "D1" "Del ett"
+#OBJEKT 6 "P1" "Projekt ett"
+`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Dimension{
+		{ID: 1, Name: "Avdelning"},
+		{ID: 21, Name: "Delprojekt", Parent: 6},
+	}
+	if got, exp := jsons(doc.Dimensions), jsons(expected); got != exp {
+		t.Error(godiffpatch.GeneratePatch("dimensions", exp, got))
+	}
+
+	// Declared dimensions override the reserved ones
+	if dim, ok := doc.Dimension(1); !ok || dim.Name != "Avdelning" {
+		t.Errorf("unexpected dimension 1: %+v", dim)
+	}
+	// Reserved dimensions are available without declaration
+	if dim, ok := doc.Dimension(6); !ok || dim.Name != "Projekt" {
+		t.Errorf("unexpected dimension 6: %+v", dim)
+	}
+	if _, ok := doc.Dimension(42); ok {
+		t.Error("unexpected dimension 42")
+	}
+
+	if objs := doc.Objects(21); len(objs) != 1 || objs[0].Text != "D1" {
+		t.Errorf("unexpected objects in dimension 21: %+v", objs)
+	}
+}
+
+func TestParseObjectBalances(t *testing.T) {
+	const input = `#KONTO 1510 "Kundfordringar"
+#IB 0 1510 1000.00
+#OIB 0 1510 {6 "P1"} 400.00
+#OUB 0 1510 {6 "P1"} 900.00 3
+#OIB -1 1510 {6 "P1"} 100.00
+`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []ObjectBalance{
+		{Year: 0, Annotation: Annotation{Tag: 6, Text: "P1"}, In: 40000, Out: 90000, OutQuantity: NewQuantity(3, 0)},
+		{Year: -1, Annotation: Annotation{Tag: 6, Text: "P1"}, In: 10000},
+	}
+	if got, exp := jsons(doc.Accounts[0].ObjectBalances), jsons(expected); got != exp {
+		t.Error(godiffpatch.GeneratePatch("object balances", exp, got))
+	}
+}
+
+func TestParseTransactionFields(t *testing.T) {
+	const input = `#VER A 1 20160102 "Salary" 20160103 "JB"
+{
+#TRANS 7010 {} 100.00
+#TRANS 7010 {} 200.00 20160105 "Salary May" 1.125 "AB"
+#TRANS 1930 {} -300.00 "" "" "" ""
+}
+`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verDate := time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)
+	expected := []Transaction{
+		{AccountID: 7010, Amount: 10000, Date: verDate, Text: "Salary", Sign: "JB"},
+		{AccountID: 7010, Amount: 20000, Date: time.Date(2016, 1, 5, 0, 0, 0, 0, time.UTC), Text: "Salary May", Quantity: NewQuantity(1125, 3), Sign: "AB"},
+		{AccountID: 1930, Amount: -30000, Date: verDate, Text: "Salary", Sign: "JB"},
+	}
+	if doc.Entries[0].Sign != "JB" {
+		t.Errorf("unexpected entry signature %q", doc.Entries[0].Sign)
+	}
+	if got, exp := jsons(doc.Entries[0].Transactions), jsons(expected); got != exp {
+		t.Error(godiffpatch.GeneratePatch("transactions", exp, got))
+	}
+}
+
+func TestParseCorrections(t *testing.T) {
+	const input = `#VER A 1 20160102 "Rent" 20160103
+{
+#TRANS 5010 {} 100.00
+#BTRANS 1930 {} -100.00 "" "" 0 "JB"
+#RTRANS 2440 {} -100.00 "" "" 0 "JB"
+#TRANS 2440 {} -100.00 "" "" 0 "JB"
+}
+`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trans := doc.Entries[0].Transactions
+	if len(trans) != 3 {
+		t.Fatalf("expected three rows in the audit trail, got %d", len(trans))
+	}
+	for i, kind := range []TransactionKind{TransactionNormal, TransactionRemoved, TransactionAdded} {
+		if trans[i].Kind != kind {
+			t.Errorf("row %d: expected kind %v, got %v", i, kind, trans[i].Kind)
+		}
+	}
+
+	eff := doc.Entries[0].EffectiveTransactions()
+	if len(eff) != 2 || eff[0].AccountID != 5010 || eff[1].AccountID != 2440 {
+		t.Errorf("unexpected effective rows: %+v", eff)
+	}
+}
+
+func TestParseCompany(t *testing.T) {
+	const input = `#PROSA "Exported for the auditor"
+#FTYP AB
+#FNR 1234
+#ORGNR 556677-8899
+#BKOD 62010
+#ADRESS "Anna Andersson" "Box 1" "123 45 Stad" "08-123 45"
+#FNAMN "Company AB"
+#TAXAR 2017
+#OMFATTN 20160630
+#VALUTA EUR
+`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balanceDate := time.Date(2016, 6, 30, 0, 0, 0, 0, time.UTC)
+	expected := Company{
+		Contact:       "Anna Andersson",
+		Address:       "Box 1",
+		PostalAddress: "123 45 Stad",
+		Phone:         "08-123 45",
+		SNI:           "62010",
+		Type:          "AB",
+		TaxYear:       2017,
+		BalanceDate:   &balanceDate,
+		Currency:      "EUR",
+		ID:            "1234",
+		Notes:         "Exported for the auditor",
+	}
+	if !reflect.DeepEqual(doc.Company, expected) {
+		t.Errorf("unexpected company %+v", doc.Company)
+	}
+	if len(doc.Unknown) != 0 {
+		t.Errorf("unexpected unknown records %+v", doc.Unknown)
+	}
+}
+
+func TestParseShortRecords(t *testing.T) {
+	// None of these may panic, and all should be reported with the line
+	// they're on
+	records := []string{
+		"#PROGRAM", "#GEN", "#SIETYP", "#ORGNR", "#FNAMN", "#RAR 0 20160101",
+		"#KPTYP", "#KONTO", "#KTYP 1930", "#IB 0 1930", "#UB 0", "#RES",
+		"#OIB 0 1930 {}", "#OUB 0", "#PSALDO 0 201601 1930", "#PBUDGET 0",
+		"#VER A 1", "#TRANS 1930 {}", "#RTRANS", "#BTRANS 1930",
+		"#DIM 6", "#UNDERDIM 21 Sub", "#OBJEKT 6", "#KSUMMA 123", "}",
+		"#ADRESS", "#BKOD", "#FTYP", "#TAXAR 2O17", "#OMFATTN", "#VALUTA", "#FNR", "#PROSA", "#SRU 1930",
+	}
+
+	for _, rec := range records {
+		input := "#FLAGGA 0\n" + rec + "\n"
+		_, err := Parse(strings.NewReader(input))
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%q: expected parse error, got %v", rec, err)
+			continue
+		}
+		if perr.Line != 2 || perr.Raw != rec {
+			t.Errorf("%q: unexpected error location %d %q", rec, perr.Line, perr.Raw)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		line  int
+		msg   string
+	}{
+		{"#RAR 0 20160101 2016-12-31\n", 1, `line 1: #RAR: invalid date "2016-12-31"`},
+		{"#KONTO 1930 Bank\n#IB 0 19x0 100.00\n", 2, `line 2: #IB: invalid account number "19x0"`},
+		{"#KONTO 1930 Bank\n#UB 0 1940 100.00\n", 2, `line 2: #UB: unknown account "1940"`},
+		{"\n#TRANS 1930 {} 100.00\n", 2, `line 2: #TRANS: transaction outside of entry`},
+		{"#VER A 1 20160101\n{\n#TRANS 1930 {x 1} 100.00\n}\n", 3, `line 3: #TRANS: invalid dimension "x"`},
+		{"#VER A 1 20160101\n{\n#TRANS 1930 {} 100.00\n", 1, `line 1: #VER: entry is not terminated`},
+		{"#KONTO 1930 Bank\n#OUB 0 1930 {6 \"P1\" 7 \"K1\"} 100.00\n", 2, `line 2: #OUB: object balance with more than one object`},
+	}
+
+	for _, tc := range cases {
+		_, err := Parse(strings.NewReader(tc.input))
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%q: expected parse error, got %v", tc.input, err)
+			continue
+		}
+		if perr.Line != tc.line || err.Error() != tc.msg {
+			t.Errorf("%q: got error %q on line %d, expected %q on line %d", tc.input, err, perr.Line, tc.msg, tc.line)
+		}
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	const input = `#KONTO 1930 "Bank"
+#KONTO 3010 "Sales"
+#IB 0 1930
+#UB 0 1930 100.00
+#VER A 1 20160102 "Sale" 20160103
+{
+#TRANS 1930 {} 100.00
+#TRANS 3010 {} -100,00
+}
+`
+
+	if _, err := Parse(strings.NewReader(input)); err == nil {
+		t.Fatal("expected error in strict mode")
+	}
+
+	doc, err := ParseWithOptions(strings.NewReader(input), ParseOptions{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Warnings) != 2 || doc.Warnings[0].Line != 3 || doc.Warnings[1].Line != 8 {
+		t.Fatalf("unexpected warnings %v", doc.Warnings)
+	}
+	if doc.Accounts[0].OutBalance != 10000 {
+		t.Error("records after the warnings should be parsed")
+	}
+	if len(doc.Entries) != 1 || len(doc.Entries[0].Transactions) != 1 {
+		t.Errorf("expected the entry with one transaction, got %+v", doc.Entries)
+	}
+}
+
+func TestParseObjectBalanceObjects(t *testing.T) {
+	const input = `#KONTO 1930 "Bank"
+#OIB 0 1930 {6 "P1"} 100.00
+#OIB 0 1930 {6 "P2" 7 "K1"} 200.00
+`
+	doc, err := ParseWithOptions(strings.NewReader(input), ParseOptions{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Warnings) != 1 || doc.Warnings[0].Line != 3 {
+		t.Errorf("unexpected warnings %v", doc.Warnings)
+	}
+	// The balance for several objects isn't given to the first of them
+	obs := doc.Accounts[0].ObjectBalances
+	if len(obs) != 1 || obs[0].Annotation.Text != "P1" || obs[0].In != 10000 {
+		t.Errorf("unexpected object balances %+v", obs)
+	}
+}
+
+func TestParseCharsets(t *testing.T) {
+	cases := []struct {
+		input   string
+		charset Charset
+	}{
+		{"#FNAMN \"R\x84ksm\x94rg\x86s AB\"\n", CharsetPC8},
+		{"#FNAMN \"R\x84ksm\x94rg\x86s AB\"\n", CharsetAuto},
+		{"#FNAMN \"R\xe4ksm\xf6rg\xe5s AB\"\n", CharsetWindows1252},
+		{"#FNAMN \"R\xe4ksm\xf6rg\xe5s AB\"\n", CharsetAuto},
+		{"#FNAMN \"Räksmörgås AB\"\n", CharsetUTF8},
+		{"#FNAMN \"Räksmörgås AB\"\n", CharsetAuto},
+		{"\ufeff#FNAMN \"Räksmörgås AB\"\n", CharsetAuto},
+	}
+
+	for _, tc := range cases {
+		doc, err := ParseWithOptions(strings.NewReader(tc.input), ParseOptions{Charset: tc.charset})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.CompanyName != "Räksmörgås AB" {
+			t.Errorf("%q: unexpected company name %q", tc.input, doc.CompanyName)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	const input = `#KONTO 3010 "Sales"
+#KONTO 1930 "Bank"
+#XBKOD 62010
+#VER A 2 20160301 "Second"
+{
+#TRANS 1930 {} 100.00
+#TRANS 3010 {} -100.00
+}
+#VER A 1 20160102 "First"
+{
+#TRANS 1930 {} 100.00
+#TRANS 3010 {} -100.00
+}
+`
+
+	doc, err := ParseWithOptions(strings.NewReader(input), ParseOptions{KeepOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Accounts[0].ID != 3010 || doc.Entries[0].ID != "2" {
+		t.Error("expected accounts and entries in file order")
+	}
+	expected := []Record{{Label: "#XBKOD", Fields: []Field{{Value: "62010"}}, Line: 3, Raw: "#XBKOD 62010"}}
+	if !reflect.DeepEqual(doc.Unknown, expected) {
+		t.Errorf("unexpected unknown records %+v", doc.Unknown)
+	}
+
+	doc, err = ParseWithOptions(strings.NewReader(input), ParseOptions{From: time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC), DiscardUnknown: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Entries) != 1 || doc.Entries[0].ID != "2" {
+		t.Errorf("expected only the entry within the range, got %+v", doc.Entries)
+	}
+	if !doc.Starts.Equal(doc.Entries[0].Date) || len(doc.Unknown) != 0 {
+		t.Error("unexpected start date or unknown records")
+	}
+}
+
 func jsons(v interface{}) string {
 	bs, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
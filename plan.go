@@ -0,0 +1,188 @@
+package sie
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The BAS chart of accounts, one account per line: number, #KTYP type,
+// normal side (D or C) and name, separated by tabs.
+//
+//go:embed plans/bas2024.tsv
+var bas2024 string
+
+// Side is the side of the ledger an account normally has its balance on.
+type Side int
+
+const (
+	Debit Side = iota
+	Credit
+)
+
+func (s Side) String() string {
+	switch s {
+	case Debit:
+		return "debit"
+	case Credit:
+		return "credit"
+	default:
+		return fmt.Sprintf("side(%d)", int(s))
+	}
+}
+
+// PlanAccount is an account in a chart of accounts.
+type PlanAccount struct {
+	ID   int
+	Name string
+	Type AccountType
+	Side Side
+}
+
+// Class returns the account class, the first digit of the account number.
+func (a PlanAccount) Class() int {
+	return a.ID / 1000
+}
+
+// Group returns the account group, the first two digits of the account
+// number.
+func (a PlanAccount) Group() int {
+	return a.ID / 100
+}
+
+// basEquityGroup is the BAS account group for equity, Eget kapital.
+const basEquityGroup = 20
+
+// Plan is a chart of accounts, such as BAS, as named by #KPTYP.
+type Plan struct {
+	Name     string
+	accounts []PlanAccount // sorted by number
+}
+
+// Account returns the account with the given number, if it is in the plan.
+func (p *Plan) Account(id int) (PlanAccount, bool) {
+	i, ok := slices.BinarySearchFunc(p.accounts, id, func(a PlanAccount, id int) int { return a.ID - id })
+	if !ok {
+		return PlanAccount{}, false
+	}
+	return p.accounts[i], true
+}
+
+// Closest returns the account with the given number or, if it is not in
+// the plan, the account group it belongs to: 1234 falls back to 1230, then
+// 1200 and 1000.
+func (p *Plan) Closest(id int) (PlanAccount, bool) {
+	for _, div := range []int{1, 10, 100, 1000} {
+		if acc, ok := p.Account(id / div * div); ok {
+			return acc, true
+		}
+	}
+	return PlanAccount{}, false
+}
+
+// Accounts returns all accounts in the plan, ordered by number.
+func (p *Plan) Accounts() []PlanAccount {
+	return slices.Clone(p.accounts)
+}
+
+var basPlan = sync.OnceValue(func() *Plan {
+	p, err := parsePlan("BAS2024", bas2024)
+	if err != nil {
+		panic(err)
+	}
+	return p
+})
+
+// BAS returns the BAS 2024 chart of accounts.
+func BAS() *Plan {
+	return basPlan()
+}
+
+// LookupPlan returns the chart of accounts for a #KPTYP name. Names of
+// earlier BAS versions, such as BAS96 or BAS2014, give BAS 2024. EUBAS97
+// is not known: its accounts were renumbered and given new meanings since,
+// and there is no mapping from them to BAS 2024, so they are treated like
+// any other unknown chart of accounts.
+func LookupPlan(name string) (*Plan, bool) {
+	name = strings.ToUpper(strings.ReplaceAll(name, " ", ""))
+	if strings.HasPrefix(name, "BAS") {
+		return BAS(), true
+	}
+	return nil, false
+}
+
+func parsePlan(name, data string) (*Plan, error) {
+	p := &Plan{Name: name}
+	for i, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("plan %s, line %d: expected 4 fields, got %d", name, i+1, len(fields))
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("plan %s, line %d: %w", name, i+1, err)
+		}
+		acc := PlanAccount{ID: id, Type: AccountType(fields[1]), Name: fields[3]}
+		if fields[2] == "C" {
+			acc.Side = Credit
+		}
+		p.accounts = append(p.accounts, acc)
+	}
+	slices.SortFunc(p.accounts, func(a, b PlanAccount) int { return a.ID - b.ID })
+	return p, nil
+}
+
+// FillAccountTypes sets the type of accounts lacking a #KTYP from the
+// declared chart of accounts, or from BAS if none is declared. Accounts
+// missing from the plan get the type of the group they belong to.
+func (d *Document) FillAccountTypes() {
+	plan, ok := LookupPlan(d.AccountPlan)
+	if !ok {
+		if !usesBAS(d.AccountPlan) {
+			return
+		}
+		plan = BAS()
+	}
+	for i := range d.Accounts {
+		acc := &d.Accounts[i]
+		if acc.Type != "" {
+			continue
+		}
+		if pa, ok := plan.Closest(acc.ID); ok {
+			acc.Type = pa.Type
+		}
+	}
+}
+
+// usesBAS returns true if accounts in the named chart of accounts have the
+// types of BAS. Without a #KPTYP the file is BAS.
+func usesBAS(plan string) bool {
+	if p, ok := LookupPlan(plan); ok {
+		return p == BAS()
+	}
+	return plan == ""
+}
+
+// validatePlan warns about accounts that are not in the declared chart of
+// accounts.
+func (d *Document) validatePlan() []Problem {
+	plan, ok := LookupPlan(d.AccountPlan)
+	if !ok {
+		return nil
+	}
+	var problems []Problem
+	for _, acc := range d.Accounts {
+		if _, ok := plan.Account(acc.ID); !ok {
+			problems = append(problems, Problem{
+				Line:      acc.Line,
+				AccountID: acc.ID,
+				Severity:  SeverityWarning,
+				Message:   fmt.Sprintf("account %d is not in the chart of accounts %s", acc.ID, d.AccountPlan),
+			})
+		}
+	}
+	return problems
+}
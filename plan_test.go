@@ -0,0 +1,108 @@
+package sie
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBASPlan(t *testing.T) {
+	bas := BAS()
+	if n := len(bas.Accounts()); n < 1000 {
+		t.Errorf("expected the full plan, got %d accounts", n)
+	}
+
+	cases := []struct {
+		id   int
+		typ  AccountType
+		side Side
+		name string
+	}{
+		{1219, "T", Credit, "Ackumulerade avskrivningar på maskiner och andra tekniska anläggningar"},
+		{1930, "T", Debit, "Företagskonto/checkkonto/affärskonto"},
+		{2099, "S", Credit, "Årets resultat"},
+		{2641, "S", Debit, "Debiterad ingående moms"},
+		{3001, "I", Credit, "Försäljning inom Sverige, 25 % moms"},
+		{5010, "K", Debit, "Lokalhyra"},
+		{8310, "I", Credit, "Ränteintäkter från omsättningstillgångar"},
+		{8999, "K", Debit, "Årets resultat"},
+	}
+	for _, tc := range cases {
+		acc, ok := bas.Account(tc.id)
+		if !ok {
+			t.Errorf("account %d not found", tc.id)
+			continue
+		}
+		if acc.Type != tc.typ || acc.Side != tc.side || acc.Name != tc.name {
+			t.Errorf("account %d: got %+v", tc.id, acc)
+		}
+	}
+
+	if _, ok := bas.Account(1934); ok {
+		t.Error("unexpected account 1934")
+	}
+	if acc, ok := bas.Closest(1934); !ok || acc.ID != 1930 {
+		t.Errorf("Closest(1934) = %v, %v", acc, ok)
+	}
+}
+
+func TestLookupPlan(t *testing.T) {
+	for _, name := range []string{"BAS2024", "BAS96", "bas 2014", "BAS"} {
+		if p, ok := LookupPlan(name); !ok || p != BAS() {
+			t.Errorf("LookupPlan(%q) did not give BAS", name)
+		}
+	}
+	for _, name := range []string{"", "EUBAS97", "K1"} {
+		if _, ok := LookupPlan(name); ok {
+			t.Errorf("LookupPlan(%q) unexpectedly succeeded", name)
+		}
+	}
+}
+
+func TestFillAccountTypes(t *testing.T) {
+	for _, plan := range []string{"", "BAS2024"} {
+		doc := &Document{
+			AccountPlan: plan,
+			Accounts: []Account{
+				{ID: 1510},
+				{ID: 2440, Type: "T"}, // kept as declared
+				{ID: 3011},
+				{ID: 7010},
+			},
+		}
+		doc.FillAccountTypes()
+		var types []string
+		for _, acc := range doc.Accounts {
+			types = append(types, string(acc.Type))
+		}
+		if got := strings.Join(types, ""); got != "TTIK" {
+			t.Errorf("plan %q: got types %q", plan, got)
+		}
+	}
+
+	for _, plan := range []string{"K1", "EUBAS97"} {
+		doc := &Document{AccountPlan: plan, Accounts: []Account{{ID: 1510}}}
+		doc.FillAccountTypes()
+		if doc.Accounts[0].Type != "" {
+			t.Errorf("plan %q: got type %q", plan, doc.Accounts[0].Type)
+		}
+	}
+}
+
+func TestValidatePlan(t *testing.T) {
+	doc := &Document{
+		AccountPlan: "BAS2024",
+		Accounts: []Account{
+			{ID: 1930, Line: 5},
+			{ID: 1934, Line: 6},
+		},
+	}
+	problems := doc.Validate()
+	if len(problems) != 1 || problems[0].AccountID != 1934 || problems[0].Severity != SeverityWarning || problems[0].Line != 6 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+
+	doc.AccountPlan = "EUBAS97"
+	if problems := doc.Validate(); len(problems) != 0 {
+		t.Errorf("unexpected problems for EUBAS97: %v", problems)
+	}
+}
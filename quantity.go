@@ -0,0 +1,249 @@
+package sie
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrOverflow is returned when the result of an operation doesn't fit in
+// a Decimal or Quantity.
+var ErrOverflow = errors.New("sie: decimal overflow")
+
+// Quantity is an exact decimal number with a scale of its own, used for
+// the quantities in #TRANS, #PSALDO, #OIB and #OUB and for other values
+// that need more decimals than amounts in öre. It holds up to 18
+// significant digits, with any number of decimals. Quantities are kept
+// without trailing zeros in the decimals, so equal quantities compare
+// equal with ==. The zero value is zero.
+type Quantity struct {
+	unscaled int64
+	scale    int32 // number of decimals
+}
+
+// NewQuantity returns the quantity unscaled × 10^-scale, so that
+// NewQuantity(1125, 3) is 1.125. It panics if the scale is negative.
+func NewQuantity(unscaled int64, scale int) Quantity {
+	if scale < 0 || scale > math.MaxInt32 {
+		panic("sie: invalid quantity scale")
+	}
+	q, err := quantityFromBig(big.NewInt(unscaled), scale)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// ParseQuantity parses a decimal number such as "-12.125", keeping all
+// its decimals.
+func ParseQuantity(s string) (Quantity, error) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 {
+		return Quantity{}, fmt.Errorf("unable to parse %q: invalid sign", s)
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" || strings.ContainsRune(s, '.') && frac == "" {
+		return Quantity{}, fmt.Errorf("unable to parse %q: missing digits", s)
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return Quantity{}, fmt.Errorf("unable to parse %q: invalid digit %q", s, r)
+		}
+	}
+	n, _ := new(big.Int).SetString(whole+frac, 10)
+	if strings.HasPrefix(s, "-") {
+		n.Neg(n)
+	}
+	q, err := quantityFromBig(n, len(frac))
+	if err != nil {
+		return Quantity{}, fmt.Errorf("unable to parse %q: %w", s, err)
+	}
+	return q, nil
+}
+
+// quantityFromBig returns the quantity n × 10^-scale, without trailing
+// zeros in the decimals.
+func quantityFromBig(n *big.Int, scale int) (Quantity, error) {
+	n = new(big.Int).Set(n)
+	ten := big.NewInt(10)
+	for scale > 0 && n.Sign() != 0 {
+		q, r := new(big.Int).QuoRem(n, ten, new(big.Int))
+		if r.Sign() != 0 {
+			break
+		}
+		n, scale = q, scale-1
+	}
+	if n.Sign() == 0 {
+		return Quantity{}, nil
+	}
+	if !n.IsInt64() || n.Int64() == math.MinInt64 || scale > math.MaxInt32 {
+		return Quantity{}, ErrOverflow
+	}
+	return Quantity{unscaled: n.Int64(), scale: int32(scale)}, nil
+}
+
+// big returns the unscaled value at the given scale, which must be at
+// least that of q.
+func (q Quantity) big(scale int) *big.Int {
+	n := big.NewInt(q.unscaled)
+	return n.Mul(n, pow10(scale-int(q.scale)))
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Scale returns the number of decimals of q.
+func (q Quantity) Scale() int {
+	return int(q.scale)
+}
+
+func (q Quantity) IsZero() bool {
+	return q.unscaled == 0
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of q.
+func (q Quantity) Sign() int {
+	switch {
+	case q.unscaled < 0:
+		return -1
+	case q.unscaled > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (q Quantity) Neg() Quantity {
+	return Quantity{unscaled: -q.unscaled, scale: q.scale}
+}
+
+func (q Quantity) Abs() Quantity {
+	if q.unscaled < 0 {
+		return q.Neg()
+	}
+	return q
+}
+
+// Cmp returns -1, 0 or +1 depending on whether q is less than, equal to or
+// greater than o.
+func (q Quantity) Cmp(o Quantity) int {
+	scale := int(max(q.scale, o.scale))
+	return q.big(scale).Cmp(o.big(scale))
+}
+
+func (q Quantity) Add(o Quantity) (Quantity, error) {
+	scale := int(max(q.scale, o.scale))
+	return quantityFromBig(new(big.Int).Add(q.big(scale), o.big(scale)), scale)
+}
+
+func (q Quantity) Sub(o Quantity) (Quantity, error) {
+	return q.Add(o.Neg())
+}
+
+// Mul returns the exact product of q and o.
+func (q Quantity) Mul(o Quantity) (Quantity, error) {
+	n := new(big.Int).Mul(big.NewInt(q.unscaled), big.NewInt(o.unscaled))
+	return quantityFromBig(n, int(q.scale)+int(o.scale))
+}
+
+// Round returns q rounded to the given number of decimals. Quantities
+// with no more decimals than that are returned as they are.
+func (q Quantity) Round(decimals int, mode RoundingMode) (Quantity, error) {
+	if decimals < 0 {
+		return Quantity{}, fmt.Errorf("sie: cannot round to %d decimals", decimals)
+	}
+	if int(q.scale) <= decimals {
+		return q, nil
+	}
+	n := quoRound(big.NewInt(q.unscaled), pow10(int(q.scale)-decimals), mode)
+	return quantityFromBig(n, decimals)
+}
+
+// Decimal returns q rounded to an amount in öre.
+func (q Quantity) Decimal(mode RoundingMode) (Decimal, error) {
+	r, err := q.Round(2, mode)
+	if err != nil {
+		return 0, err
+	}
+	return decimalFromBig(r.big(2))
+}
+
+// Quantity returns the amount as a quantity.
+func (d Decimal) Quantity() Quantity {
+	return NewQuantity(int64(d), 2)
+}
+
+func (q Quantity) String() string {
+	s := strconv.FormatInt(q.unscaled, 10)
+	if q.scale == 0 {
+		return s
+	}
+	sign := ""
+	if q.unscaled < 0 {
+		sign, s = "-", s[1:]
+	}
+	if pad := int(q.scale) + 1 - len(s); pad > 0 {
+		s = strings.Repeat("0", pad) + s
+	}
+	return sign + s[:len(s)-int(q.scale)] + "." + s[len(s)-int(q.scale):]
+}
+
+func (q Quantity) Float64() float64 {
+	f, _ := strconv.ParseFloat(q.String(), 64)
+	return f
+}
+
+func (q Quantity) MarshalText() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+func (q *Quantity) UnmarshalText(b []byte) error {
+	v, err := ParseQuantity(strings.TrimSpace(string(b)))
+	if err != nil {
+		return err
+	}
+	*q = v
+	return nil
+}
+
+// MarshalJSON writes the quantity as a JSON number with all its decimals.
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number, or a string holding one, without
+// going through float64.
+func (q *Quantity) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if uq, err := strconv.Unquote(s); err == nil {
+		s = uq
+	}
+	return q.UnmarshalText([]byte(s))
+}
+
+// Value implements driver.Valuer. The quantity is given as a decimal
+// string, which databases convert to NUMERIC columns without loss.
+func (q Quantity) Value() (driver.Value, error) {
+	return q.String(), nil
+}
+
+// Scan implements sql.Scanner. It reads back the decimal string written by
+// Value, as returned by drivers for NUMERIC and text columns.
+func (q *Quantity) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*q = Quantity{}
+	case []byte:
+		return q.UnmarshalText(v)
+	case string:
+		return q.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("unable to scan %T into Quantity", src)
+	}
+	return nil
+}
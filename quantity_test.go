@@ -0,0 +1,154 @@
+package sie
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	cases := []struct {
+		in    string
+		ok    bool
+		out   string
+		scale int
+	}{
+		{"0", true, "0", 0},
+		{"1.5", true, "1.5", 1},
+		{"1.500", true, "1.5", 1},
+		{"-0.125", true, "-0.125", 3},
+		{"+12", true, "12", 0},
+		{"0.000001", true, "0.000001", 6},
+		{"123456789012345678", true, "123456789012345678", 0},
+		{"12345678901234567890", false, "", 0},
+		{"", false, "", 0},
+		{"-", false, "", 0},
+		{"1.", false, "", 0},
+		{".5", false, "", 0},
+		{"1,5", false, "", 0},
+		{"--1", false, "", 0},
+	}
+	for _, tc := range cases {
+		q, err := ParseQuantity(tc.in)
+		if tc.ok != (err == nil) {
+			t.Errorf("%q: unexpected error %v", tc.in, err)
+		}
+		if err != nil {
+			continue
+		}
+		if q.String() != tc.out || q.Scale() != tc.scale {
+			t.Errorf("%q: got %s with scale %d", tc.in, q, q.Scale())
+		}
+	}
+
+	if q, _ := ParseQuantity("1.50"); q != NewQuantity(15, 1) {
+		t.Error("equal quantities differ")
+	}
+	if _, err := ParseQuantity("99999999999999999999"); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+}
+
+func TestQuantityArithmetic(t *testing.T) {
+	a, b := NewQuantity(125, 3), NewQuantity(15, 1) // 0.125, 1.5
+	must := func(q Quantity, err error) Quantity {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q
+	}
+	cases := []struct {
+		name string
+		got  Quantity
+		exp  string
+	}{
+		{"add", must(a.Add(b)), "1.625"},
+		{"sub", must(a.Sub(b)), "-1.375"},
+		{"mul", must(a.Mul(b)), "0.1875"},
+		{"neg", a.Neg(), "-0.125"},
+		{"abs", a.Neg().Abs(), "0.125"},
+		{"round half up", must(a.Round(2, RoundHalfUp)), "0.13"},
+		{"round half even", must(a.Round(2, RoundHalfEven)), "0.12"},
+		{"round down", must(a.Neg().Round(1, RoundDown)), "-0.1"},
+		{"round to more decimals", must(b.Round(4, RoundDown)), "1.5"},
+	}
+	for _, tc := range cases {
+		if tc.got.String() != tc.exp {
+			t.Errorf("%s: got %s, expected %s", tc.name, tc.got, tc.exp)
+		}
+	}
+
+	if a.Cmp(b) != -1 || b.Cmp(NewQuantity(1500, 3)) != 0 || b.Cmp(a) != 1 {
+		t.Error("unexpected Cmp result")
+	}
+	if a.Neg().Sign() != -1 || (Quantity{}).Sign() != 0 || !must(a.Sub(a)).IsZero() {
+		t.Error("unexpected Sign result")
+	}
+	if d, err := a.Decimal(RoundHalfUp); err != nil || d != 13 {
+		t.Errorf("unexpected amount %d, %v", d, err)
+	}
+	if q := Decimal(-150).Quantity(); q != NewQuantity(-15, 1) {
+		t.Errorf("unexpected quantity %s", q)
+	}
+
+	large := NewQuantity(9e18, 0)
+	if _, err := large.Add(large); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if _, err := large.Add(NewQuantity(1, 1)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if _, err := large.Decimal(RoundHalfUp); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+}
+
+func TestQuantityEncoding(t *testing.T) {
+	var v struct {
+		Q Quantity `json:"q"`
+	}
+	if err := json.Unmarshal([]byte(`{"q": 0.0000125}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Q != NewQuantity(125, 7) {
+		t.Errorf("unexpected quantity %s", v.Q)
+	}
+	if bs, _ := json.Marshal(v); string(bs) != `{"q":0.0000125}` {
+		t.Errorf("unexpected JSON %s", bs)
+	}
+
+	// Zero quantities are left out of transactions and balances
+	tr := Transaction{AccountID: 1930, Amount: 100}
+	if bs, _ := json.Marshal(tr); bytes.Contains(bs, []byte("quantity")) {
+		t.Errorf("unexpected quantity in %s", bs)
+	}
+	tr.Quantity = NewQuantity(15, 1)
+	bs, _ := json.Marshal(tr)
+	var got Transaction
+	if err := json.Unmarshal(bs, &got); err != nil || got.Quantity != tr.Quantity || got.Amount != 100 {
+		t.Errorf("unexpected transaction %+v from %s, %v", got, bs, err)
+	}
+	if bs, _ := json.Marshal(ObjectBalance{Out: 100, OutQuantity: NewQuantity(2, 0)}); !bytes.Contains(bs, []byte(`"outQuantity":2`)) || bytes.Contains(bs, []byte("inQuantity")) {
+		t.Errorf("unexpected object balance %s", bs)
+	}
+	if bs, _ := json.Marshal(PeriodBalance{Amount: 100}); bytes.Contains(bs, []byte("quantity")) {
+		t.Errorf("unexpected period balance %s", bs)
+	}
+
+	for _, q := range []Quantity{{}, NewQuantity(-1, 9), NewQuantity(123456789, 2)} {
+		val, err := q.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Quantity
+		if err := got.Scan(val); err != nil || got != q {
+			t.Errorf("%v: got %s, %v", val, got, err)
+		}
+	}
+	var q Quantity
+	if err := q.Scan(1.5); err == nil {
+		t.Error("expected error scanning a float")
+	}
+}
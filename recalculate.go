@@ -0,0 +1,173 @@
+package sie
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+)
+
+// objectKey identifies the part of an account booked on one object.
+type objectKey struct {
+	account int
+	tag     int
+	text    string
+}
+
+// Recalculate derives the balances of the current fiscal year from the
+// opening balances and the entries: the closing balances (#UB) or results
+// (#RES) of the accounts, the closing balances per object (#OUB) and the
+// period balances (#PSALDO). Accounts used by the entries but not declared
+// are added. This makes files that carry entries but no balances, such as
+// SIE 4I files, usable for reports.
+//
+// The returned problems are the differences between the balances in the
+// document and those derived from the entries, as reported by Validate.
+// Documents without entries, such as SIE 2 and 3 files, are left as they
+// are.
+func (d *Document) Recalculate() []Problem {
+	if len(d.Entries) == 0 {
+		return nil
+	}
+
+	sums := d.entrySums()
+	problems := d.validateBalances(sums)
+	year, _ := d.Year(0)
+
+	objectSums := make(map[objectKey]Decimal)
+	periods := make(map[int]map[time.Time]Decimal)
+	objectPeriods := make(map[objectKey]map[time.Time]Decimal)
+	for _, e := range d.Entries {
+		if d.outsideYear(e) {
+			continue
+		}
+		month := time.Date(e.Date.Year(), e.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for _, t := range e.EffectiveTransactions() {
+			addPeriod(periods, t.AccountID, month, t.Amount)
+			for _, a := range t.Annotations {
+				key := objectKey{t.AccountID, a.Tag, a.Text}
+				objectSums[key] += t.Amount
+				addPeriod(objectPeriods, key, month, t.Amount)
+			}
+		}
+	}
+
+	d.addUndeclaredAccounts(sums)
+
+	for i := range d.Accounts {
+		acc := &d.Accounts[i]
+		result := acc.hasResult(d.AccountPlan)
+		if result {
+			acc.Result = sums[acc.ID]
+		} else {
+			acc.OutBalance = acc.InBalance + sums[acc.ID]
+		}
+
+		// Object balances (#OUB) are kept for balance accounts only
+		objects := accountObjects(objectSums, acc.ID)
+		if !result {
+			for j := range acc.ObjectBalances {
+				if ob := &acc.ObjectBalances[j]; ob.Year == 0 {
+					ob.Out = ob.In
+				}
+			}
+			for _, a := range objects {
+				ob := acc.objectBalance(0, a)
+				ob.Out = ob.In + objectSums[objectKey{acc.ID, a.Tag, a.Text}]
+			}
+		}
+
+		// Period balances; for balance accounts the balance at the end of
+		// each period, for result accounts the change during it
+		var pbs []PeriodBalance
+		for _, pb := range acc.Periods {
+			if pb.Year != 0 {
+				pbs = append(pbs, pb)
+			}
+		}
+		var opening Decimal
+		if !result {
+			opening = acc.InBalance
+		}
+		pbs = appendPeriods(pbs, nil, year, opening, result, periods[acc.ID])
+		for _, a := range objects {
+			var opening Decimal
+			if !result {
+				opening = acc.objectBalance(0, a).In
+			}
+			pbs = appendPeriods(pbs, []Annotation{a}, year, opening, result, objectPeriods[objectKey{acc.ID, a.Tag, a.Text}])
+		}
+		acc.Periods = pbs
+	}
+
+	return problems
+}
+
+// addUndeclaredAccounts adds the accounts with transactions that are not
+// declared by #KONTO, named as in BAS where possible.
+func (d *Document) addUndeclaredAccounts(sums map[int]Decimal) {
+	declared := make(map[int]bool, len(d.Accounts))
+	for _, acc := range d.Accounts {
+		declared[acc.ID] = true
+	}
+	added := false
+	for _, id := range slices.Sorted(maps.Keys(sums)) {
+		if declared[id] {
+			continue
+		}
+		acc := Account{ID: id}
+		if pa, ok := BAS().Account(id); ok {
+			acc.Description = pa.Name
+		}
+		d.Accounts = append(d.Accounts, acc)
+		added = true
+	}
+	if added {
+		slices.SortFunc(d.Accounts, func(a, b Account) int { return cmp.Compare(a.ID, b.ID) })
+	}
+}
+
+// accountObjects returns the objects booked on the account, in order.
+func accountObjects(objectSums map[objectKey]Decimal, id int) []Annotation {
+	var objects []Annotation
+	for key := range objectSums {
+		if key.account == id {
+			objects = append(objects, Annotation{Tag: key.tag, Text: key.text})
+		}
+	}
+	slices.SortFunc(objects, func(a, b Annotation) int {
+		return cmp.Or(cmp.Compare(a.Tag, b.Tag), cmp.Compare(a.Text, b.Text))
+	})
+	return objects
+}
+
+func addPeriod[K comparable](periods map[K]map[time.Time]Decimal, key K, month time.Time, amount Decimal) {
+	if periods[key] == nil {
+		periods[key] = make(map[time.Time]Decimal)
+	}
+	periods[key][month] += amount
+}
+
+// appendPeriods appends the period balances for the fiscal year. For
+// result accounts they are the change during each month with transactions.
+// For balance accounts they are the balance at the end of every month of
+// the year, carried forward through months without transactions, unless
+// the account has neither an opening balance nor transactions.
+func appendPeriods(pbs []PeriodBalance, annotations []Annotation, year FiscalYear, opening Decimal, change bool, months map[time.Time]Decimal) []PeriodBalance {
+	if change {
+		for _, month := range slices.SortedFunc(maps.Keys(months), func(a, b time.Time) int { return a.Compare(b) }) {
+			pbs = append(pbs, PeriodBalance{Period: month, Annotations: annotations, Amount: months[month]})
+		}
+		return pbs
+	}
+	if opening == 0 && len(months) == 0 {
+		return pbs
+	}
+	balance := opening
+	first := time.Date(year.Starts.Year(), year.Starts.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for month := first; !month.After(year.Ends); month = month.AddDate(0, 1, 0) {
+		balance += months[month]
+		pbs = append(pbs, PeriodBalance{Period: month, Annotations: annotations, Amount: balance})
+	}
+	return pbs
+}
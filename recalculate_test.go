@@ -0,0 +1,121 @@
+package sie
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func recalculateDoc() *Document {
+	starts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ends := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	p1 := Annotation{Tag: 6, Text: "P1"}
+	doc := &Document{
+		Starts:      starts,
+		Ends:        ends,
+		Years:       []FiscalYear{{Index: 0, Starts: starts, Ends: ends}},
+		Annotations: []Annotation{p1},
+		Accounts: []Account{
+			{
+				ID: 1930, InBalance: 100000,
+				ObjectBalances: []ObjectBalance{{Annotation: p1, In: 10000}},
+			},
+			{ID: 3001},
+		},
+	}
+	var e1, e2, e3 Entry
+	e1.Date = time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	e1.Add(1930, 50000, p1).Add(3001, -50000, p1)
+	e2.Date = time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	e2.Add(1930, 20000).Add(3001, -20000)
+	e3.Date = time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	e3.Add(1930, -5000).Add(6110, 5000)
+	doc.Entries = []Entry{e1, e2, e3}
+	return doc
+}
+
+func TestRecalculate(t *testing.T) {
+	doc := recalculateDoc()
+	if problems := doc.Recalculate(); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+
+	balances := make(map[int]Balance)
+	for _, acc := range doc.Accounts {
+		balances[acc.ID] = acc.Balance(0)
+	}
+	expected := map[int]Balance{
+		1930: {In: 100000, Out: 165000},
+		3001: {Result: -70000},
+		6110: {Result: 5000},
+	}
+	if !reflect.DeepEqual(balances, expected) {
+		t.Errorf("unexpected balances: %v", balances)
+	}
+	if acc := doc.Accounts[2]; acc.Description != "Kontorsmateriel" {
+		t.Errorf("undeclared account added as %+v", acc)
+	}
+	if problems := doc.Validate(); len(problems) != 0 {
+		t.Errorf("recalculated document has problems: %v", problems)
+	}
+
+	p1 := Annotation{Tag: 6, Text: "P1"}
+	if obs := doc.Accounts[0].ObjectBalances; !reflect.DeepEqual(obs, []ObjectBalance{{Annotation: p1, In: 10000, Out: 60000}}) {
+		t.Errorf("unexpected object balances: %+v", obs)
+	}
+
+	// Balance accounts have the balance at the end of every month, also
+	// those without transactions such as February
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var periods, objectPeriods []PeriodBalance
+	for month := jan; month.Year() == 2024; month = month.AddDate(0, 1, 0) {
+		amount := Decimal(165000)
+		if month.Before(mar) {
+			amount = 150000
+		}
+		periods = append(periods, PeriodBalance{Period: month, Amount: amount})
+		objectPeriods = append(objectPeriods, PeriodBalance{Period: month, Annotations: []Annotation{p1}, Amount: 60000})
+	}
+	periods = append(periods, objectPeriods...)
+	if pbs := doc.Accounts[0].Periods; !reflect.DeepEqual(pbs, periods) {
+		t.Errorf("unexpected periods for 1930: %+v", pbs)
+	}
+	if feb := doc.Accounts[0].Periods[1]; feb.Period.Month() != time.February || feb.Amount != 150000 {
+		t.Errorf("unexpected balance for February: %+v", feb)
+	}
+
+	// Result accounts have the change in the months with transactions
+	periods = []PeriodBalance{
+		{Period: jan, Amount: -50000},
+		{Period: mar, Amount: -20000},
+		{Period: jan, Annotations: []Annotation{p1}, Amount: -50000},
+	}
+	if pbs := doc.Accounts[1].Periods; !reflect.DeepEqual(pbs, periods) {
+		t.Errorf("unexpected periods for 3001: %+v", pbs)
+	}
+}
+
+func TestRecalculateDifferences(t *testing.T) {
+	doc := recalculateDoc()
+	doc.Accounts[0].OutBalance = 170000
+	doc.Accounts[1].Result = -70000
+
+	problems := doc.Recalculate()
+	if len(problems) != 1 || problems[0].AccountID != 1930 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	if out := doc.Accounts[0].OutBalance; out != 165000 {
+		t.Errorf("closing balance %v, expected 1650.00", out)
+	}
+}
+
+func TestRecalculateWithoutEntries(t *testing.T) {
+	doc := &Document{Accounts: []Account{{ID: 1930, InBalance: 100, OutBalance: 200}}}
+	if problems := doc.Recalculate(); problems != nil {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+	if acc := doc.Accounts[0]; acc.OutBalance != 200 {
+		t.Errorf("document was modified: %+v", acc)
+	}
+}
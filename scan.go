@@ -0,0 +1,77 @@
+package sie
+
+import (
+	"bufio"
+	"io"
+)
+
+// Scanner reads a SIE file one entry at a time, for files too large to
+// hold in memory as a whole. Everything except the entries is collected in
+// the Document as usual, which for an ordinary file means it is complete
+// once the first entry has been returned.
+//
+//	sc := sie.NewScanner(r, sie.ParseOptions{})
+//	for {
+//		e, err := sc.Next()
+//		if err == io.EOF {
+//			break
+//		} else if err != nil {
+//			return err
+//		}
+//		// ... use e, and sc.Document() for the accounts
+//	}
+type Scanner struct {
+	p     *parser
+	sc    *bufio.Scanner
+	entry Entry
+	ready bool
+	err   error
+}
+
+// NewScanner returns a Scanner reading from r. The KeepOrder option is
+// implied; neither accounts nor entries are sorted.
+func NewScanner(r io.Reader, opts ParseOptions) *Scanner {
+	s := &Scanner{
+		p:  newParser(opts),
+		sc: bufio.NewScanner(decoder(r, opts.Charset)),
+	}
+	s.p.emit = func(e Entry) {
+		s.entry = e
+		s.ready = true
+	}
+	return s
+}
+
+// Next returns the next entry in the file. At the end of the file it
+// returns io.EOF, after checking for a missing checksum or an unterminated
+// entry. Errors are returned as for ParseWithOptions and are permanent.
+func (s *Scanner) Next() (Entry, error) {
+	if s.err != nil {
+		return Entry{}, s.err
+	}
+	for s.sc.Scan() {
+		if err := s.p.parseLine(s.sc.Text()); err != nil {
+			s.err = err
+			return Entry{}, err
+		}
+		if s.ready {
+			s.ready = false
+			return s.entry, nil
+		}
+	}
+	if err := s.sc.Err(); err != nil {
+		s.err = err
+		return Entry{}, err
+	}
+	if err := s.p.finish(); err != nil {
+		s.err = err
+		return Entry{}, err
+	}
+	s.err = io.EOF
+	return Entry{}, io.EOF
+}
+
+// Document returns the document read so far, without entries.
+func (s *Scanner) Document() *Document {
+	return &s.p.doc
+}
@@ -0,0 +1,77 @@
+package sie
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	fd, err := os.Open("testdata/testdata.se")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	expected, err := ParseWithOptions(fd, ParseOptions{KeepOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fd.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	sc := NewScanner(fd, ParseOptions{})
+	var entries []Entry
+	for {
+		e, err := sc.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+
+	if got, exp := jsons(entries), jsons(expected.Entries); got != exp {
+		t.Errorf("unexpected entries\ngot:  %s\nwant: %s", got, exp)
+	}
+	doc := sc.Document()
+	if len(doc.Entries) != 0 {
+		t.Error("scanner should not keep entries")
+	}
+	doc.Entries = expected.Entries
+	if got, exp := jsons(doc), jsons(expected); got != exp {
+		t.Errorf("unexpected document\ngot:  %s\nwant: %s", got, exp)
+	}
+	if _, err := sc.Next(); err != io.EOF {
+		t.Error("expected EOF to persist, got", err)
+	}
+}
+
+func TestScannerError(t *testing.T) {
+	const input = `#VER A 1 20160102 "First"
+{
+#TRANS 1930 {} 100.00
+#TRANS 3010 {} -100.00
+}
+#VER A 2 20160103 "Broken"
+{
+#TRANS 1930 {} 1x0.00
+}
+`
+
+	sc := NewScanner(strings.NewReader(input), ParseOptions{})
+	if e, err := sc.Next(); err != nil || e.ID != "1" {
+		t.Fatal("expected first entry, got", e, err)
+	}
+	_, err := sc.Next()
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Line != 8 {
+		t.Fatal("expected parse error on line 8, got", err)
+	}
+	if _, err2 := sc.Next(); err2 != err {
+		t.Error("expected the error to persist, got", err2)
+	}
+}
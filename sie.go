@@ -1,14 +1,20 @@
 package sie
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
 	"math"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
-type Decimal int64 // "cents"
+// Decimal is an amount in öre, "cents", so with a fixed scale of two
+// decimals. Values that need another scale, such as quantities and prices
+// per unit, are a Quantity, which keeps a scale of its own.
+type Decimal int64
 
 func (d Decimal) String() string {
 	if d%100 == 0 {
@@ -17,21 +23,35 @@ func (d Decimal) String() string {
 	return d.FloatString(2)
 }
 
+// FloatString returns the amount with the given number of decimals,
+// rounded half away from zero to fewer than two decimals and padded with
+// zeros to more.
 func (d Decimal) FloatString(decimals int) string {
-	if decimals <= 0 {
-		r := d / 100
-		if d%100 >= 50 {
-			r++
-		} else if d%100 <= -50 {
-			r--
+	if decimals >= 2 {
+		sign, abs := "", d
+		if d < 0 {
+			sign, abs = "-", -d
 		}
+		return fmt.Sprintf("%s%d.%02d%s", sign, abs/100, abs%100, strings.Repeat("0", decimals-2))
+	}
+	unit := Decimal(100)
+	if decimals == 1 {
+		unit = 10
+	}
+	r := d / unit
+	if rem := d % unit; rem*2 >= unit {
+		r++
+	} else if rem*2 <= -unit {
+		r--
+	}
+	if decimals <= 0 {
 		return fmt.Sprintf("%d", r)
 	}
-	abs := d
-	if d < 0 {
-		abs = -d
+	sign := ""
+	if r < 0 {
+		sign, r = "-", -r
 	}
-	return fmt.Sprintf("%d.%0*d", d/100, decimals, abs%100)
+	return fmt.Sprintf("%s%d.%d", sign, r/10, r%10)
 }
 
 func (d Decimal) Float64() float64 {
@@ -48,6 +68,9 @@ func (d *Decimal) UnmarshalJSON(b []byte) error {
 	if err != nil {
 		return fmt.Errorf("unable to parse decimal %q: %v", s, err)
 	}
+	if math.Abs(f*100) >= math.MaxInt64 {
+		return fmt.Errorf("unable to parse decimal %q: %w", s, ErrOverflow)
+	}
 	*d = Decimal(math.Round(f * 100))
 	return nil
 }
@@ -64,6 +87,9 @@ func ParseDecimal(s string) (Decimal, error) {
 	if err != nil {
 		return 0, fmt.Errorf("unable to parse %q (whole part): %v", s, err)
 	}
+	if whole > math.MaxInt64/100-1 || whole < math.MinInt64/100+1 {
+		return 0, fmt.Errorf("unable to parse %q: %w", s, ErrOverflow)
+	}
 
 	// Normalize fractional part to exactly 2 digits (truncate >2, pad <2),
 	// then round based on the third digit if present.
@@ -97,20 +123,281 @@ type Document struct {
 	Type           string       `json:"type"`
 	OrgNo          string       `json:"orgNo"`
 	CompanyName    string       `json:"companyName"`
+	Company        Company      `json:"company"`
 	AccountPlan    string       `json:"accountPlan"`
 	Accounts       []Account    `json:"accounts"`
 	Entries        []Entry      `json:"entries"`
 	Starts         time.Time    `json:"starts"`
 	Ends           time.Time    `json:"ends"`
+	Years          []FiscalYear `json:"years,omitempty"`
+	Dimensions     []Dimension  `json:"dimensions,omitempty"`
 	Annotations    []Annotation `json:"annotations"`
+
+	// Series holds the names of the voucher series, keyed by series. SIE 4
+	// has no record for them; they are the journal names of SIE 5.
+	Series map[string]string `json:"series,omitempty"`
+
+	// Unknown holds the records outside of entries that the parser doesn't
+	// handle, such as vendor extensions, in file order. They are written
+	// back out by Write.
+	Unknown []Record `json:"unknown,omitempty"`
+
+	// Warnings holds the records skipped when parsing in lenient mode.
+	Warnings []*ParseError `json:"-"`
+}
+
+// Record is a record as read from the file, with the label and the fields
+// unquoted. Object lists are kept as a single field, marked as a list.
+type Record struct {
+	Label  string  `json:"label"`
+	Fields []Field `json:"fields,omitempty"`
+
+	// Line is the line number of the record, when parsed. Write uses it to
+	// put the record back in its place relative to the accounts, entries
+	// and transactions.
+	Line int `json:"-"`
+
+	// Raw is the line as read. Write uses it as is, to reproduce the
+	// record exactly, so it must be cleared when changing the fields.
+	Raw string `json:"-"`
+}
+
+// Field is a field of a Record.
+type Field struct {
+	Value string `json:"value"`
+
+	// List is true for an object list, written within braces. The value
+	// is the contents of the list as read, such as `1 "456"`.
+	List bool `json:"list,omitempty"`
+}
+
+// Company holds the company metadata beyond the name and organisation
+// number.
+type Company struct {
+	Contact       string     `json:"contact,omitempty"`       // contact person (#ADRESS)
+	Address       string     `json:"address,omitempty"`       // street or box address (#ADRESS)
+	PostalAddress string     `json:"postalAddress,omitempty"` // postal code and town (#ADRESS)
+	Phone         string     `json:"phone,omitempty"`         // phone number (#ADRESS)
+	SNI           string     `json:"sni,omitempty"`           // industry code (#BKOD)
+	Type          string     `json:"type,omitempty"`          // company type, such as AB, E or HB (#FTYP)
+	TaxYear       int        `json:"taxYear,omitempty"`       // taxation year (#TAXAR)
+	BalanceDate   *time.Time `json:"balanceDate,omitempty"`   // date of the balances in a period file (#OMFATTN)
+	Currency      string     `json:"currency,omitempty"`      // reporting currency, SEK if empty (#VALUTA)
+	ID            string     `json:"id,omitempty"`            // company ID in the source system (#FNR)
+	Notes         string     `json:"notes,omitempty"`         // free text comment (#PROSA)
+}
+
+// FiscalYear is a fiscal year as declared by #RAR. Index 0 is the current
+// year, -1 the previous year, and so on.
+type FiscalYear struct {
+	Index  int       `json:"index"`
+	Starts time.Time `json:"starts"`
+	Ends   time.Time `json:"ends"`
 }
 
 type Account struct {
-	ID          int     `json:"id"`
-	Type        string  `json:"type"`
-	Description string  `json:"description"`
-	InBalance   Decimal `json:"inBalance"`
-	OutBalance  Decimal `json:"outBalance"`
+	ID          int         `json:"id"`
+	Type        AccountType `json:"type"`
+	Description string      `json:"description"`
+	InBalance   Decimal     `json:"inBalance"`
+	OutBalance  Decimal     `json:"outBalance"`
+	Result      Decimal     `json:"result"`
+
+	// SRU is the tax return field code for the account, from #SRU.
+	SRU string `json:"sru,omitempty"`
+
+	// Previous holds the balances for fiscal years other than the
+	// current one, keyed by year index.
+	Previous map[int]Balance `json:"previous,omitempty"`
+
+	// ObjectBalances holds the opening and closing balances per object
+	// from #OIB and #OUB.
+	ObjectBalances []ObjectBalance `json:"objectBalances,omitempty"`
+
+	// Periods and Budgets hold the per month balances and budgets from
+	// #PSALDO and #PBUDGET.
+	Periods []PeriodBalance `json:"periods,omitempty"`
+	Budgets []PeriodBalance `json:"budgets,omitempty"`
+
+	// Line is the line number of the #KONTO record, when parsed.
+	Line int `json:"-"`
+}
+
+// AccountType is the type of an account, as given by #KTYP.
+type AccountType string
+
+const (
+	Asset     AccountType = "T" // tillgång
+	Liability AccountType = "S" // skuld or eget kapital
+	Income    AccountType = "I" // intäkt
+	Cost      AccountType = "K" // kostnad
+)
+
+// NormalSide returns the side accounts of the type normally have their
+// balance on: debit for assets and costs, credit for liabilities and
+// income.
+func (t AccountType) NormalSide() Side {
+	if t == Liability || t == Income {
+		return Credit
+	}
+	return Debit
+}
+
+// EffectiveType returns the type of the account from #KTYP or, failing
+// that, from its number in the BAS plan when plan, the #KPTYP of the
+// document, is BAS or empty. It is empty for other charts of accounts and
+// for numbers outside BAS.
+func (a Account) EffectiveType(plan string) AccountType {
+	if a.Type != "" {
+		return a.Type
+	}
+	if !usesBAS(plan) {
+		return ""
+	}
+	if pa, ok := BAS().Closest(a.ID); ok {
+		return pa.Type
+	}
+	return ""
+}
+
+// IsBalanceAccount returns true for asset and liability accounts, which
+// carry their balance over to the next year. The plan is as for
+// EffectiveType.
+func (a Account) IsBalanceAccount(plan string) bool {
+	t := a.EffectiveType(plan)
+	return t == Asset || t == Liability
+}
+
+// IsResultAccount returns true for income and cost accounts, which are
+// closed against the result at the end of the year. The plan is as for
+// EffectiveType.
+func (a Account) IsResultAccount(plan string) bool {
+	t := a.EffectiveType(plan)
+	return t == Income || t == Cost
+}
+
+// NormalSide returns the side the account normally has its balance on.
+func (a Account) NormalSide(plan string) Side {
+	return a.EffectiveType(plan).NormalSide()
+}
+
+// Normalize returns the booked amount d with the sign changed for accounts
+// normally on the credit side, so that income, liabilities and equity are
+// positive like assets and costs.
+func (a Account) Normalize(plan string, d Decimal) Decimal {
+	if a.NormalSide(plan) == Credit {
+		return -d
+	}
+	return d
+}
+
+// Balance is the set of balances for an account in one fiscal year.
+type Balance struct {
+	In     Decimal `json:"in"`
+	Out    Decimal `json:"out"`
+	Result Decimal `json:"result"`
+}
+
+// ObjectBalance is the part of an account's opening and closing balance
+// that is booked on one object in a fiscal year.
+type ObjectBalance struct {
+	Year        int        `json:"year"`
+	Annotation  Annotation `json:"annotation"`
+	In          Decimal    `json:"in"`
+	Out         Decimal    `json:"out"`
+	InQuantity  Quantity   `json:"inQuantity"`  // left out of JSON when zero
+	OutQuantity Quantity   `json:"outQuantity"` // left out of JSON when zero
+}
+
+// MarshalJSON leaves out the quantities when they are zero.
+func (ob ObjectBalance) MarshalJSON() ([]byte, error) {
+	type plain ObjectBalance
+	v := struct {
+		plain
+		InQuantity  *Quantity `json:"inQuantity,omitempty"`
+		OutQuantity *Quantity `json:"outQuantity,omitempty"`
+	}{plain: plain(ob)}
+	if !ob.InQuantity.IsZero() {
+		v.InQuantity = &ob.InQuantity
+	}
+	if !ob.OutQuantity.IsZero() {
+		v.OutQuantity = &ob.OutQuantity
+	}
+	return json.Marshal(v)
+}
+
+// objectBalance returns the object balance for the given year and object,
+// adding it if it doesn't exist.
+func (a *Account) objectBalance(year int, ann Annotation) *ObjectBalance {
+	for i, ob := range a.ObjectBalances {
+		if ob.Year == year && ob.Annotation.Equals(ann) {
+			return &a.ObjectBalances[i]
+		}
+	}
+	a.ObjectBalances = append(a.ObjectBalances, ObjectBalance{Year: year, Annotation: ann})
+	return &a.ObjectBalances[len(a.ObjectBalances)-1]
+}
+
+// PeriodBalance is the balance or budget for an account in one period
+// (month) of a fiscal year. For result accounts the amount is the change
+// during the period. Without annotations it covers the whole account,
+// otherwise only the part booked on the given object.
+type PeriodBalance struct {
+	Year        int          `json:"year"`
+	Period      time.Time    `json:"period"`
+	Annotations []Annotation `json:"annotations,omitempty"`
+	Amount      Decimal      `json:"amount"`
+	Quantity    Quantity     `json:"quantity"` // left out of JSON when zero
+}
+
+// MarshalJSON leaves out the quantity when it is zero.
+func (pb PeriodBalance) MarshalJSON() ([]byte, error) {
+	type plain PeriodBalance
+	v := struct {
+		plain
+		Quantity *Quantity `json:"quantity,omitempty"`
+	}{plain: plain(pb)}
+	if !pb.Quantity.IsZero() {
+		v.Quantity = &pb.Quantity
+	}
+	return json.Marshal(v)
+}
+
+// Balance returns the account balances for the given fiscal year index.
+func (a Account) Balance(year int) Balance {
+	if year == 0 {
+		return Balance{In: a.InBalance, Out: a.OutBalance, Result: a.Result}
+	}
+	return a.Previous[year]
+}
+
+func (a *Account) setBalance(year int, fn func(*Balance)) {
+	if year == 0 {
+		b := a.Balance(0)
+		fn(&b)
+		a.InBalance, a.OutBalance, a.Result = b.In, b.Out, b.Result
+		return
+	}
+	if a.Previous == nil {
+		a.Previous = make(map[int]Balance)
+	}
+	b := a.Previous[year]
+	fn(&b)
+	a.Previous[year] = b
+}
+
+// Year returns the fiscal year with the given index, where 0 is the
+// current year and -1 the previous one.
+func (d *Document) Year(index int) (FiscalYear, bool) {
+	for _, y := range d.Years {
+		if y.Index == index {
+			return y, true
+		}
+	}
+	if index == 0 && !d.Starts.IsZero() {
+		return FiscalYear{Index: 0, Starts: d.Starts, Ends: d.Ends}, true
+	}
+	return FiscalYear{}, false
 }
 
 type Entry struct {
@@ -119,15 +406,145 @@ type Entry struct {
 	Date         time.Time     `json:"date"`
 	Description  string        `json:"description"`
 	Filed        time.Time     `json:"filed"`
+	Sign         string        `json:"sign,omitempty"`
 	Transactions []Transaction `json:"transactions"`
+
+	// Unknown holds the records within the entry that the parser doesn't
+	// handle.
+	Unknown []Record `json:"unknown,omitempty"`
+
+	// Line is the line number of the #VER record, when parsed.
+	Line int `json:"-"`
 }
 
+// Transaction is a row in an entry. The date, text and signature default to
+// those of the entry when not given on the row.
 type Transaction struct {
-	AccountID   int          `json:"accountId"`
-	Annotations []Annotation `json:"annotations,omitempty"`
-	Amount      Decimal      `json:"amount"`
+	AccountID   int             `json:"accountId"`
+	Annotations []Annotation    `json:"annotations,omitempty"`
+	Amount      Decimal         `json:"amount"`
+	Date        time.Time       `json:"date"`
+	Text        string          `json:"text,omitempty"`
+	Quantity    Quantity        `json:"quantity"` // left out of JSON when zero
+	Sign        string          `json:"sign,omitempty"`
+	Kind        TransactionKind `json:"kind,omitempty"`
+
+	// Line is the line number of the record, when parsed.
+	Line int `json:"-"`
 }
 
+// MarshalJSON leaves out the quantity when it is zero.
+func (t Transaction) MarshalJSON() ([]byte, error) {
+	type plain Transaction
+	v := struct {
+		plain
+		Quantity *Quantity `json:"quantity,omitempty"`
+	}{plain: plain(t)}
+	if !t.Quantity.IsZero() {
+		v.Quantity = &t.Quantity
+	}
+	return json.Marshal(v)
+}
+
+// TransactionKind tells regular transaction rows apart from rows added
+// (#RTRANS) or removed (#BTRANS) when an entry was corrected.
+type TransactionKind int
+
+const (
+	TransactionNormal TransactionKind = iota
+	TransactionAdded
+	TransactionRemoved
+)
+
+func (k TransactionKind) MarshalText() ([]byte, error) {
+	switch k {
+	case TransactionNormal:
+		return []byte("normal"), nil
+	case TransactionAdded:
+		return []byte("added"), nil
+	case TransactionRemoved:
+		return []byte("removed"), nil
+	default:
+		return nil, fmt.Errorf("unknown transaction kind %d", int(k))
+	}
+}
+
+func (k *TransactionKind) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "normal", "":
+		*k = TransactionNormal
+	case "added":
+		*k = TransactionAdded
+	case "removed":
+		*k = TransactionRemoved
+	default:
+		return fmt.Errorf("unknown transaction kind %q", b)
+	}
+	return nil
+}
+
+// EffectiveTransactions returns the rows that make up the entry after
+// corrections, that is, all rows except those that were removed. The
+// Transactions field holds the full audit trail.
+func (e Entry) EffectiveTransactions() []Transaction {
+	res := make([]Transaction, 0, len(e.Transactions))
+	for _, t := range e.Transactions {
+		if t.Kind != TransactionRemoved {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// Dimension is an object dimension as declared by #DIM, or by #UNDERDIM
+// for a dimension that is a subdivision of a parent dimension.
+type Dimension struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Parent int    `json:"parent,omitempty"`
+}
+
+// ReservedDimensions are the dimensions defined by the SIE standard. They
+// may be used without being declared in the file.
+var ReservedDimensions = []Dimension{
+	{ID: 1, Name: "Kostnadsställe"},
+	{ID: 2, Name: "Kostnadsbärare", Parent: 1},
+	{ID: 6, Name: "Projekt"},
+	{ID: 7, Name: "Anställd"},
+	{ID: 8, Name: "Kund"},
+	{ID: 9, Name: "Leverantör"},
+	{ID: 10, Name: "Faktura"},
+}
+
+// Dimension returns the dimension with the given number, as declared in
+// the file or else as reserved by the standard.
+func (d *Document) Dimension(id int) (Dimension, bool) {
+	for _, dim := range d.Dimensions {
+		if dim.ID == id {
+			return dim, true
+		}
+	}
+	for _, dim := range ReservedDimensions {
+		if dim.ID == id {
+			return dim, true
+		}
+	}
+	return Dimension{}, false
+}
+
+// Objects returns the objects declared with #OBJEKT in the given dimension.
+func (d *Document) Objects(dim int) []Annotation {
+	var objs []Annotation
+	for _, a := range d.Annotations {
+		if a.Tag == dim {
+			objs = append(objs, a)
+		}
+	}
+	return objs
+}
+
+// Annotation is an object in a dimension, as declared by #OBJEKT and
+// referenced in object lists. The Tag is the dimension number.
 type Annotation struct {
 	Tag         int    `json:"tag"`
 	Text        string `json:"text,omitempty"`
@@ -163,25 +580,141 @@ func (d *Document) CopyForAnnotation(ann Annotation) *Document {
 			cpy.Entries = append(cpy.Entries, e2)
 		}
 	}
+	cpy.Accounts = copyAccounts(d.Accounts, func(acc *Account) {
+		acc.Periods = periodsForAnnotation(acc.Periods, ann)
+		acc.Budgets = periodsForAnnotation(acc.Budgets, ann)
+
+		// The account balances become those of the object
+		acc.InBalance, acc.OutBalance, acc.Result = 0, 0, 0
+		acc.Previous = nil
+		for _, ob := range acc.ObjectBalances {
+			if ob.Annotation.Equals(ann) {
+				acc.setBalance(ob.Year, func(b *Balance) {
+					b.In, b.Out = ob.In, ob.Out
+				})
+			}
+		}
+		acc.ObjectBalances = nil
+	})
 	return &cpy
 }
 
+// CopyWithoutAnnotations returns a copy of the document with only the
+// transactions that aren't booked on any object. The balances are copied
+// as they are, since objects from different dimensions may overlap; use
+// CopyWithoutDimension to split balances.
 func (d *Document) CopyWithoutAnnotations() *Document {
 	cpy := *d
-	cpy.Entries = make([]Entry, 0, len(d.Entries))
-	for _, e := range d.Entries {
+	cpy.Entries = filterTransactions(d.Entries, func(t Transaction) bool {
+		return len(t.Annotations) == 0
+	})
+	return &cpy
+}
+
+// CopyWithoutDimension returns a copy of the document with what is not
+// booked on any object in the given dimension: the transactions without
+// such an object, and the account totals less the period and object
+// balances of the objects in the dimension.
+func (d *Document) CopyWithoutDimension(dim int) *Document {
+	inDim := func(a Annotation) bool { return a.Tag == dim }
+	cpy := *d
+	cpy.Entries = filterTransactions(d.Entries, func(t Transaction) bool {
+		return !slices.ContainsFunc(t.Annotations, inDim)
+	})
+	cpy.Accounts = copyAccounts(d.Accounts, func(acc *Account) {
+		acc.Periods = periodsWithoutDimension(acc.Periods, dim)
+		acc.Budgets = periodsWithoutDimension(acc.Budgets, dim)
+
+		// The account balances become what remains when the balances of
+		// the objects in the dimension are removed
+		acc.Previous = maps.Clone(acc.Previous)
+		for _, ob := range acc.ObjectBalances {
+			if !inDim(ob.Annotation) {
+				continue
+			}
+			acc.setBalance(ob.Year, func(b *Balance) {
+				b.In -= ob.In
+				b.Out -= ob.Out
+			})
+		}
+		acc.ObjectBalances = nil
+	})
+	return &cpy
+}
+
+// filterTransactions returns the entries with only the transactions for
+// which keep returns true. Entries left without transactions are dropped.
+func filterTransactions(entries []Entry, keep func(Transaction) bool) []Entry {
+	res := make([]Entry, 0, len(entries))
+	for _, e := range entries {
 		e2 := e
 		e2.Transactions = make([]Transaction, 0, len(e.Transactions))
 		for _, t := range e.Transactions {
-			if len(t.Annotations) == 0 {
+			if keep(t) {
 				e2.Transactions = append(e2.Transactions, t)
 			}
 		}
 		if len(e2.Transactions) > 0 {
-			cpy.Entries = append(cpy.Entries, e2)
+			res = append(res, e2)
 		}
 	}
-	return &cpy
+	return res
+}
+
+// copyAccounts returns a copy of the accounts, with fn applied to each.
+func copyAccounts(accounts []Account, fn func(*Account)) []Account {
+	cpy := make([]Account, len(accounts))
+	for i, acc := range accounts {
+		fn(&acc)
+		cpy[i] = acc
+	}
+	return cpy
+}
+
+// periodsForAnnotation returns the period balances booked on the given
+// object, as account totals for that object.
+func periodsForAnnotation(pbs []PeriodBalance, ann Annotation) []PeriodBalance {
+	var res []PeriodBalance
+	for _, pb := range pbs {
+		for _, a := range pb.Annotations {
+			if a.Equals(ann) {
+				pb.Annotations = nil
+				res = append(res, pb)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// periodsWithoutDimension returns the part of the account totals that is
+// not booked on any object in the dimension.
+func periodsWithoutDimension(pbs []PeriodBalance, dim int) []PeriodBalance {
+	inDim := func(a Annotation) bool { return a.Tag == dim }
+	var res []PeriodBalance
+	for _, pb := range pbs {
+		if len(pb.Annotations) != 0 {
+			continue
+		}
+		var overflow error
+		for _, other := range pbs {
+			if slices.ContainsFunc(other.Annotations, inDim) && other.Year == pb.Year && other.Period.Equal(pb.Period) {
+				pb.Amount -= other.Amount
+				var err error
+				if pb.Quantity, err = pb.Quantity.Sub(other.Quantity); err != nil {
+					overflow = err
+				}
+			}
+		}
+		if overflow != nil {
+			// There is no quantity to show that would be right
+			pb.Quantity = Quantity{}
+		}
+		if pb.Amount != 0 || !pb.Quantity.IsZero() {
+			res = append(res, pb)
+		}
+	}
+	return res
 }
 
 func (d *Document) AddEntriesFrom(other *Document) {
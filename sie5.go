@@ -0,0 +1,759 @@
+package sie
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// SIE 5 is an XML format. A complete export has the root element Sie,
+// while an import file has SieEntry and a subset of the contents.
+
+const sie5Namespace = "http://www.sie.se/sie5"
+
+type sie5File struct {
+	XMLName    xml.Name
+	FileInfo   sie5FileInfo    `xml:"FileInfo"`
+	Accounts   []sie5Account   `xml:"Accounts>Account"`
+	Dimensions *sie5Dimensions `xml:"Dimensions,omitempty"`
+	Journals   []sie5Journal   `xml:"Journal"`
+}
+
+type sie5FileInfo struct {
+	SoftwareProduct    sie5SoftwareProduct `xml:"SoftwareProduct"`
+	FileCreation       sie5FileCreation    `xml:"FileCreation"`
+	Company            sie5Company         `xml:"Company"`
+	FiscalYears        *sie5FiscalYears    `xml:"FiscalYears,omitempty"`
+	AccountingCurrency *sie5Currency       `xml:"AccountingCurrency,omitempty"`
+}
+
+type sie5SoftwareProduct struct {
+	Name    string `xml:"name,attr"`
+	Version string `xml:"version,attr"`
+}
+
+type sie5FileCreation struct {
+	Time string `xml:"time,attr"`
+	By   string `xml:"by,attr"`
+}
+
+type sie5Company struct {
+	OrganizationID string `xml:"organizationId,attr"`
+	ClientID       string `xml:"clientId,attr,omitempty"`
+	Name           string `xml:"name,attr"`
+}
+
+// Optional container elements are pointers, as encoding/xml writes empty
+// a>b parent elements even with omitempty
+
+type sie5FiscalYears struct {
+	FiscalYears []sie5FiscalYear `xml:"FiscalYear"`
+}
+
+type sie5FiscalYear struct {
+	Start   string `xml:"start,attr"` // YYYY-MM
+	End     string `xml:"end,attr"`   // YYYY-MM
+	Primary bool   `xml:"primary,attr"`
+}
+
+type sie5Currency struct {
+	Currency string `xml:"currency,attr"`
+}
+
+type sie5Account struct {
+	ID              string        `xml:"id,attr"`
+	Name            string        `xml:"name,attr"`
+	Type            string        `xml:"type,attr"`
+	OpeningBalances []sie5Balance `xml:"OpeningBalance"`
+	ClosingBalances []sie5Balance `xml:"ClosingBalance"`
+	Budgets         []sie5Balance `xml:"Budget"`
+}
+
+type sie5Balance struct {
+	Month    string          `xml:"month,attr"` // YYYY-MM
+	Amount   string          `xml:"amount,attr"`
+	Quantity string          `xml:"quantity,attr,omitempty"`
+	Objects  []sie5ObjectRef `xml:"ObjectReference"`
+}
+
+type sie5ObjectRef struct {
+	DimID    string `xml:"dimId,attr"`
+	ObjectID string `xml:"objectId,attr"`
+}
+
+type sie5Dimensions struct {
+	Dimensions []sie5Dimension `xml:"Dimension"`
+}
+
+type sie5Dimension struct {
+	ID      string       `xml:"id,attr"`
+	Name    string       `xml:"name,attr"`
+	Objects []sie5Object `xml:"Object"`
+}
+
+type sie5Object struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"name,attr"`
+}
+
+type sie5Journal struct {
+	ID      string             `xml:"id,attr,omitempty"`
+	Name    string             `xml:"name,attr,omitempty"`
+	Entries []sie5JournalEntry `xml:"JournalEntry"`
+}
+
+type sie5JournalEntry struct {
+	ID            string            `xml:"id,attr,omitempty"`
+	JournalDate   string            `xml:"journalDate,attr"`
+	Text          string            `xml:"text,attr,omitempty"`
+	EntryInfo     *sie5EntryInfo    `xml:"EntryInfo,omitempty"`
+	LedgerEntries []sie5LedgerEntry `xml:"LedgerEntry"`
+}
+
+type sie5EntryInfo struct {
+	Date string `xml:"date,attr"`
+	By   string `xml:"by,attr"`
+}
+
+type sie5LedgerEntry struct {
+	AccountID  string          `xml:"accountId,attr"`
+	Amount     string          `xml:"amount,attr"`
+	Quantity   string          `xml:"quantity,attr,omitempty"`
+	Text       string          `xml:"text,attr,omitempty"`
+	LedgerDate string          `xml:"ledgerDate,attr,omitempty"`
+	Objects    []sie5ObjectRef `xml:"ObjectReference"`
+	EntryInfo  *sie5EntryInfo  `xml:"EntryInfo,omitempty"`
+	Overstrike *sie5Overstrike `xml:"Overstrike,omitempty"`
+}
+
+type sie5Overstrike struct {
+	Date string `xml:"date,attr"`
+	By   string `xml:"by,attr"`
+}
+
+// SIE 5 account types and their SIE 4 #KTYP equivalents
+var sie5AccountTypes = map[string]AccountType{
+	"asset":     Asset,
+	"liability": Liability,
+	"equity":    Liability,
+	"income":    Income,
+	"cost":      Cost,
+}
+
+// ParseSIE5 parses a SIE 5 XML file, either a complete export (Sie) or an
+// import file (SieEntry), into a Document. The primary fiscal year becomes
+// year 0 and the years before it -1, -2 and so on. Closing balances of
+// income and cost accounts are taken as the result for the year.
+func ParseSIE5(r io.Reader) (*Document, error) {
+	var f sie5File
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	if f.XMLName.Space != sie5Namespace || f.XMLName.Local != "Sie" && f.XMLName.Local != "SieEntry" {
+		return nil, fmt.Errorf("not a SIE 5 file: root element %s in %q", f.XMLName.Local, f.XMLName.Space)
+	}
+
+	doc := &Document{
+		ProgramName:    f.FileInfo.SoftwareProduct.Name,
+		ProgramVersion: f.FileInfo.SoftwareProduct.Version,
+		GeneratedBy:    f.FileInfo.FileCreation.By,
+		OrgNo:          f.FileInfo.Company.OrganizationID,
+		CompanyName:    f.FileInfo.Company.Name,
+	}
+	doc.Company.ID = f.FileInfo.Company.ClientID
+	if c := f.FileInfo.AccountingCurrency; c != nil {
+		doc.Company.Currency = c.Currency
+	}
+	if t := f.FileInfo.FileCreation.Time; t != "" {
+		gen, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return nil, fmt.Errorf("file creation time: %w", err)
+		}
+		doc.GeneratedAt = time.Date(gen.Year(), gen.Month(), gen.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	if fys := f.FileInfo.FiscalYears; fys != nil {
+		if err := sie5Years(doc, fys.FiscalYears); err != nil {
+			return nil, err
+		}
+	}
+
+	var dims []sie5Dimension
+	if f.Dimensions != nil {
+		dims = f.Dimensions.Dimensions
+	}
+	for _, dim := range dims {
+		id, err := parseInt(dim.ID, "dimension")
+		if err != nil {
+			return nil, err
+		}
+		doc.Dimensions = append(doc.Dimensions, Dimension{ID: id, Name: dim.Name})
+		for _, obj := range dim.Objects {
+			doc.Annotations = append(doc.Annotations, Annotation{Tag: id, Text: obj.ID, Description: obj.Name})
+		}
+	}
+
+	for _, a := range f.Accounts {
+		acc, err := sie5ParseAccount(doc, a)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", a.ID, err)
+		}
+		doc.Accounts = append(doc.Accounts, acc)
+	}
+
+	for _, j := range f.Journals {
+		if j.Name != "" {
+			if doc.Series == nil {
+				doc.Series = make(map[string]string)
+			}
+			doc.Series[j.ID] = j.Name
+		}
+		for _, je := range j.Entries {
+			e, err := sie5ParseEntry(j, je)
+			if err != nil {
+				return nil, fmt.Errorf("journal %s entry %s: %w", j.ID, je.ID, err)
+			}
+			doc.Entries = append(doc.Entries, e)
+			if _, ok := doc.yearDeclared(0); !ok {
+				if doc.Starts.IsZero() || doc.Starts.After(e.Date) {
+					doc.Starts = e.Date
+				}
+				if doc.Ends.IsZero() || doc.Ends.Before(e.Date) {
+					doc.Ends = e.Date
+				}
+			}
+		}
+	}
+
+	sortDocument(doc)
+	return doc, nil
+}
+
+// ParseAny parses either a SIE 4 or a SIE 5 file, depending on whether
+// the contents look like XML. SIE 4 files are parsed with the default
+// options.
+func ParseAny(r io.Reader) (*Document, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(512)
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	if bytes.HasPrefix(bytes.TrimSpace(head), []byte("<")) {
+		return ParseSIE5(br)
+	}
+	return Parse(br)
+}
+
+// sie5Years sets the fiscal years of the document. The years are numbered
+// relative to the primary one, or to the latest one if none is marked
+// primary.
+func sie5Years(doc *Document, fys []sie5FiscalYear) error {
+	var years []FiscalYear
+	primary := -1
+	for i, fy := range fys {
+		starts, err := time.Parse("2006-01", fy.Start)
+		if err != nil {
+			return fmt.Errorf("fiscal year start: %w", err)
+		}
+		ends, err := time.Parse("2006-01", fy.End)
+		if err != nil {
+			return fmt.Errorf("fiscal year end: %w", err)
+		}
+		years = append(years, FiscalYear{Starts: starts, Ends: ends.AddDate(0, 1, -1)})
+		if fy.Primary {
+			primary = i
+		}
+	}
+	if len(years) == 0 {
+		return nil
+	}
+
+	primaryStarts := years[len(years)-1].Starts
+	if primary >= 0 {
+		primaryStarts = years[primary].Starts
+	} else {
+		for _, y := range years {
+			if y.Starts.After(primaryStarts) {
+				primaryStarts = y.Starts
+			}
+		}
+	}
+	slices.SortFunc(years, func(a, b FiscalYear) int { return a.Starts.Compare(b.Starts) })
+	idx := slices.IndexFunc(years, func(y FiscalYear) bool { return y.Starts.Equal(primaryStarts) })
+	for i := range years {
+		years[i].Index = i - idx
+	}
+	slices.SortFunc(years, func(a, b FiscalYear) int { return b.Index - a.Index })
+
+	doc.Years = years
+	doc.Starts = years[0].Starts
+	doc.Ends = years[0].Ends
+	for _, y := range years {
+		if y.Index == 0 {
+			doc.Starts, doc.Ends = y.Starts, y.Ends
+		}
+	}
+	return nil
+}
+
+// sie5YearIndex returns the index of the fiscal year containing the month.
+func sie5YearIndex(doc *Document, month string) (int, time.Time, error) {
+	t, err := time.Parse("2006-01", month)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid month %q", month)
+	}
+	for _, y := range doc.Years {
+		if !t.Before(y.Starts) && !t.After(y.Ends) {
+			return y.Index, t, nil
+		}
+	}
+	if len(doc.Years) == 0 {
+		return 0, t, nil
+	}
+	return 0, t, fmt.Errorf("month %s is outside the fiscal years", month)
+}
+
+func sie5ParseAccount(doc *Document, a sie5Account) (Account, error) {
+	id, err := parseInt(a.ID, "account number")
+	if err != nil {
+		return Account{}, err
+	}
+	acc := Account{
+		ID:          id,
+		Type:        sie5AccountTypes[a.Type],
+		Description: a.Name,
+	}
+	result := a.Type == "income" || a.Type == "cost"
+
+	balances := func(bs []sie5Balance, closing bool) error {
+		for _, b := range bs {
+			year, _, err := sie5YearIndex(doc, b.Month)
+			if err != nil {
+				return err
+			}
+			amount, err := ParseDecimal(b.Amount)
+			if err != nil {
+				return err
+			}
+			quantity, err := sie5OptionalQuantity(b.Quantity)
+			if err != nil {
+				return err
+			}
+			anns, err := sie5Objects(b.Objects)
+			if err != nil {
+				return err
+			}
+			if len(anns) > 1 {
+				return fmt.Errorf("balance for %s has more than one object", b.Month)
+			}
+			if len(anns) == 1 {
+				ob := acc.objectBalance(year, anns[0])
+				if closing {
+					ob.Out, ob.OutQuantity = amount, quantity
+				} else {
+					ob.In, ob.InQuantity = amount, quantity
+				}
+				continue
+			}
+			acc.setBalance(year, func(bal *Balance) {
+				switch {
+				case !closing:
+					bal.In = amount
+				case result:
+					bal.Result = amount
+				default:
+					bal.Out = amount
+				}
+			})
+		}
+		return nil
+	}
+	if err := balances(a.OpeningBalances, false); err != nil {
+		return Account{}, err
+	}
+	if err := balances(a.ClosingBalances, true); err != nil {
+		return Account{}, err
+	}
+
+	for _, b := range a.Budgets {
+		year, month, err := sie5YearIndex(doc, b.Month)
+		if err != nil {
+			return Account{}, err
+		}
+		pb := PeriodBalance{Year: year, Period: month}
+		if pb.Amount, err = ParseDecimal(b.Amount); err != nil {
+			return Account{}, err
+		}
+		if pb.Quantity, err = sie5OptionalQuantity(b.Quantity); err != nil {
+			return Account{}, err
+		}
+		if pb.Annotations, err = sie5Objects(b.Objects); err != nil {
+			return Account{}, err
+		}
+		acc.Budgets = append(acc.Budgets, pb)
+	}
+
+	return acc, nil
+}
+
+func sie5ParseEntry(j sie5Journal, je sie5JournalEntry) (Entry, error) {
+	date, err := sie5Date(je.JournalDate)
+	if err != nil {
+		return Entry{}, err
+	}
+	e := Entry{
+		ID:          je.ID,
+		Type:        j.ID,
+		Date:        date,
+		Description: je.Text,
+	}
+	if info := je.EntryInfo; info != nil {
+		if info.Date != "" {
+			if e.Filed, err = sie5Date(info.Date); err != nil {
+				return Entry{}, err
+			}
+		}
+		e.Sign = info.By
+	}
+
+	for _, le := range je.LedgerEntries {
+		t := Transaction{
+			Date: e.Date,
+			Text: e.Description,
+			Sign: e.Sign,
+		}
+		if t.AccountID, err = parseInt(le.AccountID, "account number"); err != nil {
+			return Entry{}, err
+		}
+		if t.Amount, err = ParseDecimal(le.Amount); err != nil {
+			return Entry{}, err
+		}
+		if t.Quantity, err = sie5OptionalQuantity(le.Quantity); err != nil {
+			return Entry{}, err
+		}
+		if t.Annotations, err = sie5Objects(le.Objects); err != nil {
+			return Entry{}, err
+		}
+		if le.Text != "" {
+			t.Text = le.Text
+		}
+		if le.LedgerDate != "" {
+			if t.Date, err = sie5Date(le.LedgerDate); err != nil {
+				return Entry{}, err
+			}
+		}
+		if le.EntryInfo != nil && le.EntryInfo.By != "" {
+			t.Sign = le.EntryInfo.By
+		}
+		if le.Overstrike != nil {
+			t.Kind = TransactionRemoved
+			if le.Overstrike.By != "" {
+				t.Sign = le.Overstrike.By
+			}
+		}
+		e.Transactions = append(e.Transactions, t)
+	}
+	return e, nil
+}
+
+func sie5Objects(refs []sie5ObjectRef) ([]Annotation, error) {
+	var anns []Annotation
+	for _, ref := range refs {
+		tag, err := parseInt(ref.DimID, "dimension")
+		if err != nil {
+			return nil, err
+		}
+		anns = append(anns, Annotation{Tag: tag, Text: ref.ObjectID})
+	}
+	return anns, nil
+}
+
+func sie5Date(s string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return t, nil
+}
+
+func sie5OptionalQuantity(s string) (Quantity, error) {
+	if s == "" {
+		return Quantity{}, nil
+	}
+	return ParseQuantity(s)
+}
+
+// WriteSIE5 writes the document as a complete SIE 5 export file, with the
+// root element Sie. The standard requires such files to be signed with an
+// XML signature, which must be added afterwards by the signing party.
+func WriteSIE5(w io.Writer, doc *Document) error {
+	return writeSIE5(w, doc, false)
+}
+
+// WriteSIE5Entry writes the document as a SIE 5 import file, with the
+// root element SieEntry. Import files hold accounts, dimensions and
+// entries but no fiscal years, balances or budgets.
+func WriteSIE5Entry(w io.Writer, doc *Document) error {
+	return writeSIE5(w, doc, true)
+}
+
+func writeSIE5(w io.Writer, doc *Document, entry bool) error {
+	f := sie5File{
+		XMLName: xml.Name{Space: sie5Namespace, Local: "Sie"},
+		FileInfo: sie5FileInfo{
+			SoftwareProduct: sie5SoftwareProduct{Name: doc.ProgramName, Version: doc.ProgramVersion},
+			Company: sie5Company{
+				OrganizationID: doc.OrgNo,
+				ClientID:       doc.Company.ID,
+				Name:           doc.CompanyName,
+			},
+		},
+	}
+	if f.FileInfo.SoftwareProduct.Name == "" {
+		f.FileInfo.SoftwareProduct.Name = "kastelo.dev/sie"
+	}
+	generatedAt := doc.GeneratedAt
+	if generatedAt.IsZero() {
+		generatedAt = time.Now()
+	}
+	f.FileInfo.FileCreation = sie5FileCreation{
+		Time: generatedAt.UTC().Format(time.RFC3339),
+		By:   cmp.Or(doc.GeneratedBy, f.FileInfo.SoftwareProduct.Name),
+	}
+	if doc.Company.Currency != "" {
+		f.FileInfo.AccountingCurrency = &sie5Currency{Currency: doc.Company.Currency}
+	}
+	if entry {
+		f.XMLName.Local = "SieEntry"
+	} else {
+		f.FileInfo.FiscalYears = sie5FiscalYearsFor(doc)
+	}
+
+	for _, acc := range doc.Accounts {
+		typ, err := sie5AccountType(acc, doc.AccountPlan)
+		if err != nil {
+			return err
+		}
+		a := sie5Account{
+			ID:   strconv.Itoa(acc.ID),
+			Name: acc.Description,
+			Type: typ,
+		}
+		if !entry {
+			sie5AccountBalances(doc, acc, &a)
+		}
+		f.Accounts = append(f.Accounts, a)
+	}
+
+	f.Dimensions = sie5DimensionsFor(doc)
+
+	journals := make(map[string]*sie5Journal)
+	for _, e := range doc.Entries {
+		j, ok := journals[e.Type]
+		if !ok {
+			j = &sie5Journal{ID: e.Type, Name: doc.Series[e.Type]}
+			journals[e.Type] = j
+		}
+		j.Entries = append(j.Entries, sie5JournalEntryFor(e))
+	}
+	for _, id := range slices.Sorted(maps.Keys(journals)) {
+		f.Journals = append(f.Journals, *journals[id])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// sie5AccountType returns the SIE 5 type of the account, from its #KTYP
+// type or, failing that, its number in the BAS plan. SIE 5 requires a type,
+// so accounts without one are an error. #KTYP doesn't tell equity from
+// liabilities, so equity is the BAS equity group in files using BAS.
+func sie5AccountType(acc Account, plan string) (string, error) {
+	switch t := acc.EffectiveType(plan); t {
+	case Asset:
+		return "asset", nil
+	case Liability:
+		if pa, ok := BAS().Closest(acc.ID); ok && usesBAS(plan) && pa.Group() == basEquityGroup {
+			return "equity", nil
+		}
+		return "liability", nil
+	case Income:
+		return "income", nil
+	case Cost:
+		return "cost", nil
+	case "":
+		return "", fmt.Errorf("account %d has no type", acc.ID)
+	default:
+		return "", fmt.Errorf("account %d has unknown type %q", acc.ID, t)
+	}
+}
+
+func sie5FiscalYearsFor(doc *Document) *sie5FiscalYears {
+	years := doc.Years
+	if len(years) == 0 {
+		if y, ok := doc.Year(0); ok {
+			years = []FiscalYear{y}
+		}
+	}
+	var fys []sie5FiscalYear
+	for _, y := range years {
+		fys = append(fys, sie5FiscalYear{
+			Start:   y.Starts.Format("2006-01"),
+			End:     y.Ends.Format("2006-01"),
+			Primary: y.Index == 0,
+		})
+	}
+	if len(fys) == 0 {
+		return nil
+	}
+	slices.SortFunc(fys, func(a, b sie5FiscalYear) int { return cmp.Compare(a.Start, b.Start) })
+	return &sie5FiscalYears{FiscalYears: fys}
+}
+
+// sie5AccountBalances adds the opening and closing balances, object
+// balances and budgets of the account. Balances for years that aren't
+// declared are left out, as they can't be given a month.
+func sie5AccountBalances(doc *Document, acc Account, a *sie5Account) {
+	result := a.Type == "income" || a.Type == "cost"
+	years := balanceYears(doc)
+	slices.Reverse(years)
+	for _, year := range years {
+		fy, ok := doc.Year(year)
+		if !ok {
+			continue
+		}
+		starts, ends := fy.Starts.Format("2006-01"), fy.Ends.Format("2006-01")
+		bal := acc.Balance(year)
+		closing := bal.Out
+		if result && bal.Result != 0 {
+			closing = bal.Result
+		}
+		if bal.In != 0 {
+			a.OpeningBalances = append(a.OpeningBalances, sie5Balance{Month: starts, Amount: bal.In.FloatString(2)})
+		}
+		if closing != 0 {
+			a.ClosingBalances = append(a.ClosingBalances, sie5Balance{Month: ends, Amount: closing.FloatString(2)})
+		}
+		for _, ob := range acc.ObjectBalances {
+			if ob.Year != year {
+				continue
+			}
+			refs := sie5ObjectRefs([]Annotation{ob.Annotation})
+			if ob.In != 0 || !ob.InQuantity.IsZero() {
+				a.OpeningBalances = append(a.OpeningBalances, sie5Balance{Month: starts, Amount: ob.In.FloatString(2), Quantity: sie5Quantity(ob.InQuantity), Objects: refs})
+			}
+			if ob.Out != 0 || !ob.OutQuantity.IsZero() {
+				a.ClosingBalances = append(a.ClosingBalances, sie5Balance{Month: ends, Amount: ob.Out.FloatString(2), Quantity: sie5Quantity(ob.OutQuantity), Objects: refs})
+			}
+		}
+	}
+	for _, pb := range acc.Budgets {
+		a.Budgets = append(a.Budgets, sie5Balance{
+			Month:    pb.Period.Format("2006-01"),
+			Amount:   pb.Amount.FloatString(2),
+			Quantity: sie5Quantity(pb.Quantity),
+			Objects:  sie5ObjectRefs(pb.Annotations),
+		})
+	}
+}
+
+// sie5DimensionsFor returns the dimensions with their objects. Dimensions
+// that are used by objects but not declared are included with their
+// reserved name, or a generic one.
+func sie5DimensionsFor(doc *Document) *sie5Dimensions {
+	var dims []sie5Dimension
+	index := make(map[int]int)
+	add := func(id int) {
+		if _, ok := index[id]; ok {
+			return
+		}
+		name := fmt.Sprintf("Dimension %d", id)
+		if dim, ok := doc.Dimension(id); ok {
+			name = dim.Name
+		}
+		index[id] = len(dims)
+		dims = append(dims, sie5Dimension{ID: strconv.Itoa(id), Name: name})
+	}
+	for _, dim := range doc.Dimensions {
+		add(dim.ID)
+	}
+	for _, ann := range doc.Annotations {
+		add(ann.Tag)
+		d := &dims[index[ann.Tag]]
+		d.Objects = append(d.Objects, sie5Object{ID: ann.Text, Name: cmp.Or(ann.Description, ann.Text)})
+	}
+	if len(dims) == 0 {
+		return nil
+	}
+	return &sie5Dimensions{Dimensions: dims}
+}
+
+// sie5JournalEntryFor converts the entry, leaving out the transaction
+// date, text and signature where they are the same as for the entry.
+func sie5JournalEntryFor(e Entry) sie5JournalEntry {
+	je := sie5JournalEntry{
+		ID:          e.ID,
+		JournalDate: e.Date.Format("2006-01-02"),
+		Text:        e.Description,
+	}
+	if !e.Filed.IsZero() || e.Sign != "" {
+		filed := e.Filed
+		if filed.IsZero() {
+			filed = e.Date
+		}
+		je.EntryInfo = &sie5EntryInfo{Date: filed.Format("2006-01-02"), By: e.Sign}
+	}
+
+	for _, t := range e.Transactions {
+		le := sie5LedgerEntry{
+			AccountID: strconv.Itoa(t.AccountID),
+			Amount:    t.Amount.FloatString(2),
+			Quantity:  sie5Quantity(t.Quantity),
+			Objects:   sie5ObjectRefs(t.Annotations),
+		}
+		if t.Text != e.Description {
+			le.Text = t.Text
+		}
+		if !t.Date.IsZero() && !t.Date.Equal(e.Date) {
+			le.LedgerDate = t.Date.Format("2006-01-02")
+		}
+		if t.Kind == TransactionRemoved {
+			le.Overstrike = &sie5Overstrike{Date: je.JournalDate, By: t.Sign}
+			if je.EntryInfo != nil {
+				le.Overstrike.Date = je.EntryInfo.Date
+			}
+		} else if t.Sign != e.Sign {
+			le.EntryInfo = &sie5EntryInfo{Date: je.JournalDate, By: t.Sign}
+		}
+		je.LedgerEntries = append(je.LedgerEntries, le)
+	}
+	return je
+}
+
+func sie5ObjectRefs(anns []Annotation) []sie5ObjectRef {
+	var refs []sie5ObjectRef
+	for _, a := range anns {
+		refs = append(refs, sie5ObjectRef{DimID: strconv.Itoa(a.Tag), ObjectID: a.Text})
+	}
+	return refs
+}
+
+func sie5Quantity(q Quantity) string {
+	if q.IsZero() {
+		return ""
+	}
+	return q.String()
+}
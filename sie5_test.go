@@ -0,0 +1,259 @@
+package sie
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	godiffpatch "github.com/sourcegraph/go-diff-patch"
+)
+
+func TestParseSIE5(t *testing.T) {
+	fd, err := os.Open("testdata/testdata.sie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	doc, err := ParseSIE5(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.ProgramName != "Test Program" || doc.CompanyName != "Företaget AB" || doc.OrgNo != "556677-8899" || doc.Company.ID != "F1" {
+		t.Errorf("unexpected file info %+v", doc)
+	}
+	if !doc.GeneratedAt.Equal(time.Date(2017, 1, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected generation date %v", doc.GeneratedAt)
+	}
+
+	expectedYears := []FiscalYear{
+		{Index: 0, Starts: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), Ends: time.Date(2016, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{Index: -1, Starts: time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), Ends: time.Date(2015, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	if got, exp := jsons(doc.Years), jsons(expectedYears); got != exp {
+		t.Errorf("unexpected years\ngot:  %s\nwant: %s", got, exp)
+	}
+	if !doc.Starts.Equal(expectedYears[0].Starts) || !doc.Ends.Equal(expectedYears[0].Ends) {
+		t.Errorf("unexpected document period %v - %v", doc.Starts, doc.Ends)
+	}
+
+	bank := doc.Accounts[0]
+	if bank.ID != 1930 || bank.Type != "T" || bank.InBalance != 10000 || bank.OutBalance != 25050 || bank.Balance(-1).Out != 10000 {
+		t.Errorf("unexpected bank account %+v", bank)
+	}
+	sales := doc.Accounts[2]
+	if sales.ID != 3010 || sales.Type != "I" || sales.Result != -20000 || sales.OutBalance != 0 {
+		t.Errorf("unexpected sales account %+v", sales)
+	}
+	if len(sales.ObjectBalances) != 1 || sales.ObjectBalances[0].Out != -15000 || sales.ObjectBalances[0].Annotation.Text != "P1" {
+		t.Errorf("unexpected object balances %+v", sales.ObjectBalances)
+	}
+	if len(sales.Budgets) != 1 || sales.Budgets[0].Amount != -30000 || !sales.Budgets[0].Period.Equal(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected budgets %+v", sales.Budgets)
+	}
+
+	if len(doc.Dimensions) != 1 || len(doc.Annotations) != 1 || doc.Annotations[0].Description != "Projekt 1" {
+		t.Errorf("unexpected dimensions %+v %+v", doc.Dimensions, doc.Annotations)
+	}
+
+	if doc.Series["A"] != "Försäljning" || doc.Series["B"] != "Leverantörsfakturor" {
+		t.Errorf("unexpected series %v", doc.Series)
+	}
+
+	if len(doc.Entries) != 2 {
+		t.Fatalf("expected two entries, got %d", len(doc.Entries))
+	}
+	e := doc.Entries[1]
+	if e.Type != "B" || e.ID != "1" || e.Sign != "AB" || e.Description != "Hyra" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if tr := e.Transactions[0]; tr.Text != "Hyra februari" || tr.Sign != "AB" || !tr.Date.Equal(e.Date) {
+		t.Errorf("unexpected transaction %+v", tr)
+	}
+	if tr := e.Transactions[1]; !tr.Date.Equal(time.Date(2016, 2, 2, 0, 0, 0, 0, time.UTC)) || tr.Text != "Hyra" {
+		t.Errorf("unexpected transaction %+v", tr)
+	}
+	if e.Transactions[2].Kind != TransactionRemoved || len(e.EffectiveTransactions()) != 2 {
+		t.Error("expected the overstruck row to be removed")
+	}
+	if tr := doc.Entries[0].Transactions[1]; tr.Quantity != NewQuantity(2, 0) || len(tr.Annotations) != 1 {
+		t.Errorf("unexpected transaction %+v", tr)
+	}
+}
+
+func TestParseAny(t *testing.T) {
+	for _, name := range []string{"testdata/testdata.se", "testdata/testdata.sie"} {
+		fd, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := ParseAny(fd)
+		fd.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(doc.Entries) != 2 {
+			t.Errorf("%s: expected two entries, got %d", name, len(doc.Entries))
+		}
+	}
+}
+
+func TestWriteSIE5Roundtrip(t *testing.T) {
+	for _, name := range []string{"testdata/testdata.se", "testdata/testdata.sie"} {
+		fd, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := ParseAny(fd)
+		fd.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := WriteSIE5(&buf, doc); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseSIE5(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// SIE 5 has no file type or account plan, the fiscal years are
+		// always declared, and result accounts have their result as the
+		// closing balance
+		doc.Type = ""
+		doc.AccountPlan = ""
+		if len(doc.Years) == 0 {
+			doc.Years = got.Years
+		}
+		for i, acc := range doc.Accounts {
+			if acc.ID >= 3000 && acc.Result == 0 {
+				doc.Accounts[i].Result, doc.Accounts[i].OutBalance = acc.OutBalance, 0
+			}
+		}
+		if docStr, gotStr := jsons(doc), jsons(got); docStr != gotStr {
+			t.Error(godiffpatch.GeneratePatch(name, docStr, gotStr))
+		}
+	}
+}
+
+func TestWriteSIE5Entry(t *testing.T) {
+	fd, err := os.Open("testdata/testdata.sie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	doc, err := ParseSIE5(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSIE5Entry(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`<SieEntry xmlns="http://www.sie.se/sie5">`)) {
+		t.Error("expected a SieEntry root element")
+	}
+	for _, elem := range []string{`<Journal id="B" name="Leverantörsfakturor">`, `<Overstrike date="2016-02-01" by="AB">`} {
+		if !bytes.Contains(buf.Bytes(), []byte(elem)) {
+			t.Errorf("expected %s in import file", elem)
+		}
+	}
+	for _, elem := range []string{"<FiscalYears>", "<OpeningBalance", "<ClosingBalance", "<Budget"} {
+		if bytes.Contains(buf.Bytes(), []byte(elem)) {
+			t.Errorf("unexpected %s in import file", elem)
+		}
+	}
+
+	got, err := ParseSIE5(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := jsons(got.Entries), jsons(doc.Entries); got != exp {
+		t.Errorf("unexpected entries\ngot:  %s\nwant: %s", got, exp)
+	}
+}
+
+func TestWriteSIE5AccountType(t *testing.T) {
+	doc, err := Parse(strings.NewReader("#KPTYP K1\n#KONTO 1930 Bank\n#KONTO 3000 Sales\n#KTYP 3000 I\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSIE5(io.Discard, doc); err == nil || !strings.Contains(err.Error(), "account 1930 has no type") {
+		t.Errorf("expected an error for the untyped account, got %v", err)
+	}
+
+	doc.Accounts[0].Type = Asset
+	var buf bytes.Buffer
+	if err := WriteSIE5(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`type="asset"`)) || !bytes.Contains(buf.Bytes(), []byte(`type="income"`)) {
+		t.Errorf("unexpected account types in\n%s", buf.Bytes())
+	}
+
+	// Equity is told from liabilities by the BAS groups, for BAS files only
+	for plan, exp := range map[string]string{"BAS2024": "equity", "K1": "liability"} {
+		if typ, err := sie5AccountType(Account{ID: 2081, Type: Liability}, plan); err != nil || typ != exp {
+			t.Errorf("plan %s: got %q, %v", plan, typ, err)
+		}
+	}
+	if typ, _ := sie5AccountType(Account{ID: 2440}, ""); typ != "liability" {
+		t.Errorf("got %q for 2440", typ)
+	}
+}
+
+// TestWriteSIE5Schema validates the written files against the SIE 5 schema
+// with xmllint. It needs the path of sie5.xsd in $SIE5_XSD, as set in CI.
+func TestWriteSIE5Schema(t *testing.T) {
+	schema := os.Getenv("SIE5_XSD")
+	if schema == "" {
+		t.Skip("SIE5_XSD is not set")
+	}
+	fd, err := os.Open("testdata/testdata.se")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	doc, err := Parse(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, write := range map[string]func(io.Writer, *Document) error{"Sie": WriteSIE5, "SieEntry": WriteSIE5Entry} {
+		path := filepath.Join(t.TempDir(), name+".sie")
+		out, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := write(out, doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := out.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if bs, err := exec.Command("xmllint", "--noout", "--schema", schema, path).CombinedOutput(); err != nil {
+			t.Errorf("%s: %v\n%s", name, err, bs)
+		}
+	}
+}
+
+func TestParseSIE5ObjectBalanceObjects(t *testing.T) {
+	fd, err := os.ReadFile("testdata/testdata.sie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A balance for two objects can't be given to either of them
+	input := strings.Replace(string(fd), `<ObjectReference dimId="6" objectId="P1"/>`,
+		`<ObjectReference dimId="6" objectId="P1"/><ObjectReference dimId="1" objectId="K1"/>`, 1)
+	if _, err := ParseSIE5(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), "more than one object") {
+		t.Errorf("expected an error for the object balance, got %v", err)
+	}
+}
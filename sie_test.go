@@ -3,6 +3,7 @@ package sie
 import (
 	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -79,6 +80,9 @@ func TestDocumentJSONRoundtrip(t *testing.T) {
 	if err != nil {
 		t.Fatal("marshal:", err)
 	}
+	if strings.Contains(string(data), "balanceDate") {
+		t.Error("unset balance date in JSON")
+	}
 
 	var got Document
 	if err := json.Unmarshal(data, &got); err != nil {
@@ -99,6 +103,7 @@ func TestDecimalJSONValues(t *testing.T) {
 		{100, "1"},
 		{150, "1.50"},
 		{-150, "-1.50"},
+		{-50, "-0.50"},
 		{25050, "250.50"},
 		{-25050, "-250.50"},
 		{1000000, "10000"},
@@ -125,3 +130,125 @@ func TestDecimalJSONValues(t *testing.T) {
 		}
 	}
 }
+
+func TestCopyPeriodBalances(t *testing.T) {
+	jan := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	p1 := Annotation{Tag: 6, Text: "P1"}
+	doc := &Document{
+		Accounts: []Account{
+			{
+				ID: 3010,
+				Periods: []PeriodBalance{
+					{Period: jan, Amount: -100000},
+					{Period: jan, Annotations: []Annotation{p1}, Amount: -40000},
+				},
+			},
+		},
+	}
+
+	forP1 := doc.CopyForAnnotation(p1)
+	if exp := []PeriodBalance{{Period: jan, Amount: -40000}}; !reflect.DeepEqual(forP1.Accounts[0].Periods, exp) {
+		t.Errorf("unexpected periods for P1: %+v", forP1.Accounts[0].Periods)
+	}
+
+	other := doc.CopyWithoutDimension(6)
+	if exp := []PeriodBalance{{Period: jan, Amount: -60000}}; !reflect.DeepEqual(other.Accounts[0].Periods, exp) {
+		t.Errorf("unexpected periods without annotations: %+v", other.Accounts[0].Periods)
+	}
+
+	if len(doc.Accounts[0].Periods) != 2 {
+		t.Error("original document was modified")
+	}
+
+	// Objects in another dimension overlap those in the first and are
+	// only subtracted within their own dimension
+	cc := Annotation{Tag: 1, Text: "CC1"}
+	doc.Accounts[0].Periods = append(doc.Accounts[0].Periods, PeriodBalance{Period: jan, Annotations: []Annotation{cc}, Amount: -70000})
+	if exp := []PeriodBalance{{Period: jan, Amount: -60000}}; !reflect.DeepEqual(doc.CopyWithoutDimension(6).Accounts[0].Periods, exp) {
+		t.Errorf("unexpected periods without dimension 6: %+v", doc.CopyWithoutDimension(6).Accounts[0].Periods)
+	}
+	if exp := []PeriodBalance{{Period: jan, Amount: -30000}}; !reflect.DeepEqual(doc.CopyWithoutDimension(1).Accounts[0].Periods, exp) {
+		t.Errorf("unexpected periods without dimension 1: %+v", doc.CopyWithoutDimension(1).Accounts[0].Periods)
+	}
+}
+
+func TestCopyObjectBalances(t *testing.T) {
+	p1 := Annotation{Tag: 6, Text: "P1"}
+	doc := &Document{
+		Accounts: []Account{
+			{
+				ID: 1510, InBalance: 100000, OutBalance: 300000,
+				ObjectBalances: []ObjectBalance{
+					{Annotation: p1, In: 40000, Out: 90000},
+				},
+			},
+		},
+	}
+
+	forP1 := doc.CopyForAnnotation(p1)
+	if acc := forP1.Accounts[0]; acc.InBalance != 40000 || acc.OutBalance != 90000 {
+		t.Errorf("unexpected balances for P1: %v, %v", acc.InBalance, acc.OutBalance)
+	}
+
+	other := doc.CopyWithoutDimension(6)
+	if acc := other.Accounts[0]; acc.InBalance != 60000 || acc.OutBalance != 210000 {
+		t.Errorf("unexpected balances without annotations: %v, %v", acc.InBalance, acc.OutBalance)
+	}
+
+	if acc := doc.Accounts[0]; acc.InBalance != 100000 || len(acc.ObjectBalances) != 1 {
+		t.Error("original document was modified")
+	}
+
+	// Balances of objects in another dimension are left alone
+	cc := Annotation{Tag: 1, Text: "CC1"}
+	doc.Accounts[0].ObjectBalances = append(doc.Accounts[0].ObjectBalances, ObjectBalance{Annotation: cc, In: 100000, Out: 250000})
+	if acc := doc.CopyWithoutDimension(6).Accounts[0]; acc.InBalance != 60000 || acc.OutBalance != 210000 {
+		t.Errorf("unexpected balances without dimension 6: %v, %v", acc.InBalance, acc.OutBalance)
+	}
+	if acc := doc.CopyWithoutDimension(1).Accounts[0]; acc.InBalance != 0 || acc.OutBalance != 50000 {
+		t.Errorf("unexpected balances without dimension 1: %v, %v", acc.InBalance, acc.OutBalance)
+	}
+}
+
+func TestAccountTypes(t *testing.T) {
+	cases := []struct {
+		acc     Account
+		plan    string
+		typ     AccountType
+		balance bool
+		result  bool
+		side    Side
+	}{
+		{Account{ID: 1930}, "", Asset, true, false, Debit},
+		{Account{ID: 2440}, "", Liability, true, false, Credit},
+		{Account{ID: 3001}, "", Income, false, true, Credit},
+		{Account{ID: 8310}, "", Income, false, true, Credit},
+		{Account{ID: 8410}, "", Cost, false, true, Debit},
+		{Account{ID: 5010, Type: Asset}, "", Asset, true, false, Debit}, // #KTYP wins over the number
+		{Account{ID: 1510, Type: Income}, "", Income, false, true, Credit},
+		{Account{ID: 9100}, "", "", false, false, Debit},
+		{Account{ID: 1930}, "BAS2024", Asset, true, false, Debit},
+		{Account{ID: 1930}, "EUBAS97", "", false, false, Debit},
+		{Account{ID: 1930}, "K1", "", false, false, Debit}, // no BAS fallback
+		{Account{ID: 1930, Type: Liability}, "K1", Liability, true, false, Credit},
+	}
+	for _, tc := range cases {
+		if typ := tc.acc.EffectiveType(tc.plan); typ != tc.typ {
+			t.Errorf("%d in %q: type %q, expected %q", tc.acc.ID, tc.plan, typ, tc.typ)
+		}
+		balance, result := tc.acc.IsBalanceAccount(tc.plan), tc.acc.IsResultAccount(tc.plan)
+		if balance != tc.balance || result != tc.result {
+			t.Errorf("%d in %q: balance %v result %v", tc.acc.ID, tc.plan, balance, result)
+		}
+		if side := tc.acc.NormalSide(tc.plan); side != tc.side {
+			t.Errorf("%d in %q: side %v, expected %v", tc.acc.ID, tc.plan, side, tc.side)
+		}
+	}
+
+	if d := (Account{ID: 3001}).Normalize("", -10000); d != 10000 {
+		t.Errorf("income normalised to %v", d)
+	}
+	if d := (Account{ID: 5010}).Normalize("", 10000); d != 10000 {
+		t.Errorf("cost normalised to %v", d)
+	}
+}
@@ -2,6 +2,7 @@ package sie
 
 import (
 	"bufio"
+	"bytes"
 	"strings"
 	"unicode/utf8"
 )
@@ -16,6 +17,26 @@ func splitWords(s string) []string {
 	return res
 }
 
+// splitFields splits a record into fields like splitWords, but keeps track
+// of which fields are object lists. Their contents are kept as is.
+func splitFields(s string) []Field {
+	data := []byte(s)
+	var res []Field
+	for len(data) > 0 {
+		advance, token, _ := scanWords(data, true)
+		if token == nil {
+			break
+		}
+		if rest := bytes.TrimLeft(data, " \t"); rest[0] == '{' {
+			res = append(res, Field{Value: string(token), List: true})
+		} else {
+			res = append(res, Field{Value: maybeUnquote(string(token))})
+		}
+		data = data[advance:]
+	}
+	return res
+}
+
 func scanWords(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	// Skip leading spaces.
 	start := 0
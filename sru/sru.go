@@ -0,0 +1,258 @@
+// Package sru produces tax return files in the SRU format used by
+// Skatteverket, from the #SRU codes and balances of a SIE file.
+//
+// A submission is a pair of files: INFO.SRU describing the sender and
+// BLANKETTER.SRU holding the forms. Write produces the income statement and
+// balance sheet form INK2R and the tax adjustments form INK2S for a
+// limited company.
+package sru
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"kastelo.dev/sie"
+)
+
+// Field codes computed from the result rather than from account mappings
+const (
+	fieldProfit    = "7450" // INK2R, årets resultat, vinst
+	fieldLoss      = "7550" // INK2R, årets resultat, förlust
+	fieldTaxProfit = "7650" // INK2S, årets resultat, vinst
+	fieldTaxLoss   = "7750" // INK2S, årets resultat, förlust
+)
+
+// Some systems book the result on 8999, Årets resultat, against 2099
+// before exporting. It doesn't count towards the result.
+const yearResultAccount = 8999
+
+var (
+	ErrMissingOrgNo         = errors.New("document has no organisation number")
+	ErrMissingPostalAddress = errors.New("document has no postal address")
+)
+
+type Options struct {
+	// Period is the form version, such as "2024P4", as given in the
+	// Skatteverket specification for the tax year. Required.
+	Period string
+
+	// Program is the name of the producing program. Defaults to
+	// "kastelo.dev/sie".
+	Program string
+
+	// Created is the time the files are created. Defaults to now.
+	Created time.Time
+
+	// Email is the contact e-mail address, if any.
+	Email string
+}
+
+// Amounts returns the amount per SRU code, in whole kronor. Balance
+// accounts contribute their closing balance and result accounts their
+// result, with the sign changed for accounts normally on the credit side.
+// Amounts are thus positive when on the normal side of the accounts, and
+// negative otherwise, as for the fields that can go either way.
+func Amounts(doc *sie.Document) map[string]int64 {
+	sums := make(map[string]sie.Decimal)
+	for _, acc := range doc.Accounts {
+		if acc.SRU == "" {
+			continue
+		}
+		sums[acc.SRU] += acc.Normalize(doc.AccountPlan, amount(acc, doc.AccountPlan))
+	}
+
+	res := make(map[string]int64, len(sums))
+	for code, sum := range sums {
+		res[code] = kronor(sum)
+	}
+	return res
+}
+
+// Result returns the result of the year in whole kronor, positive for a
+// profit.
+func Result(doc *sie.Document) int64 {
+	var sum sie.Decimal
+	for _, acc := range doc.Accounts {
+		if acc.IsResultAccount(doc.AccountPlan) && acc.ID != yearResultAccount {
+			sum += amount(acc, doc.AccountPlan)
+		}
+	}
+	// Income is credited, so a profit is a negative sum
+	return -kronor(sum)
+}
+
+// Write writes the INFO.SRU and BLANKETTER.SRU files for the document to
+// info and forms.
+func Write(info, forms io.Writer, doc *sie.Document, opts Options) error {
+	if opts.Period == "" {
+		return errors.New("form period is required")
+	}
+	orgNo, err := identity(doc.OrgNo)
+	if err != nil {
+		return err
+	}
+	if opts.Program == "" {
+		opts.Program = "kastelo.dev/sie"
+	}
+	if opts.Created.IsZero() {
+		opts.Created = time.Now()
+	}
+
+	co := doc.Company
+	postNo, town, err := splitPostalAddress(co.PostalAddress)
+	if err != nil {
+		return err
+	}
+
+	w := newWriter(info)
+	w.record("#DATABESKRIVNING_START")
+	w.record("#PRODUKT", "SRU")
+	w.record("#SKAPAD", opts.Created.Format("20060102"), opts.Created.Format("150405"))
+	w.record("#PROGRAM", opts.Program)
+	w.record("#FILNAMN", "BLANKETTER.SRU")
+	w.record("#DATABESKRIVNING_SLUT")
+	w.record("#MEDIELEV_START")
+	w.record("#ORGNR", orgNo)
+	w.record("#NAMN", doc.CompanyName)
+	if co.Address != "" {
+		w.record("#ADRESS", co.Address)
+	}
+	w.record("#POSTNR", postNo)
+	w.record("#POSTORT", town)
+	if co.Contact != "" {
+		w.record("#KONTAKT", co.Contact)
+	}
+	if co.Phone != "" {
+		w.record("#TELEFON", co.Phone)
+	}
+	if opts.Email != "" {
+		w.record("#EMAIL", opts.Email)
+	}
+	w.record("#MEDIELEV_SLUT")
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	amounts := Amounts(doc)
+	result := Result(doc)
+	if result >= 0 {
+		amounts[fieldProfit] = result
+	} else {
+		amounts[fieldLoss] = -result
+	}
+	adjustments := make(map[string]int64)
+	if result >= 0 {
+		adjustments[fieldTaxProfit] = result
+	} else {
+		adjustments[fieldTaxLoss] = -result
+	}
+
+	w = newWriter(forms)
+	w.form("INK2R-"+opts.Period, orgNo, doc.CompanyName, opts.Created, amounts)
+	w.form("INK2S-"+opts.Period, orgNo, doc.CompanyName, opts.Created, adjustments)
+	w.record("#FIL_SLUT")
+	return w.flush()
+}
+
+// amount returns the closing balance of a balance account, or the result
+// of a result account.
+func amount(acc sie.Account, plan string) sie.Decimal {
+	if acc.IsResultAccount(plan) && acc.Result != 0 {
+		return acc.Result
+	}
+	return acc.OutBalance
+}
+
+// kronor returns d rounded to whole kronor, halves away from zero.
+func kronor(d sie.Decimal) int64 {
+	if d < 0 {
+		return -kronor(-d)
+	}
+	return (int64(d) + 50) / 100
+}
+
+// identity returns the organisation number in the twelve digit form used
+// in SRU files, with the century prefix 16 for legal persons.
+func identity(orgNo string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, orgNo)
+	switch len(digits) {
+	case 0:
+		return "", ErrMissingOrgNo
+	case 10:
+		return "16" + digits, nil
+	case 12:
+		return digits, nil
+	default:
+		return "", fmt.Errorf("invalid organisation number %q", orgNo)
+	}
+}
+
+// splitPostalAddress splits an address such as "123 45 Stad" into the
+// postal code and the town, both of which INFO.SRU requires.
+func splitPostalAddress(s string) (string, string, error) {
+	if strings.TrimSpace(s) == "" {
+		return "", "", ErrMissingPostalAddress
+	}
+	var code strings.Builder
+	i := 0
+	for ; i < len(s) && code.Len() < 5; i++ {
+		switch {
+		case s[i] >= '0' && s[i] <= '9':
+			code.WriteByte(s[i])
+		case s[i] != ' ':
+			return "", "", fmt.Errorf("invalid postal address %q: expected a postal code first", s)
+		}
+	}
+	town := strings.TrimSpace(s[i:])
+	if code.Len() < 5 || town == "" {
+		return "", "", fmt.Errorf("invalid postal address %q: expected a postal code and a town", s)
+	}
+	return code.String(), town, nil
+}
+
+type writer struct {
+	bw *bufio.Writer
+}
+
+func newWriter(w io.Writer) *writer {
+	enc := encoding.ReplaceUnsupported(charmap.ISO8859_1.NewEncoder())
+	return &writer{bw: bufio.NewWriter(enc.Writer(w))}
+}
+
+func (w *writer) form(name, orgNo, companyName string, created time.Time, amounts map[string]int64) {
+	w.record("#BLANKETT", name)
+	w.record("#IDENTITET", orgNo, created.Format("20060102"), created.Format("150405"))
+	w.record("#NAMN", companyName)
+	codes := make([]string, 0, len(amounts))
+	for code := range amounts {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		if amounts[code] != 0 {
+			w.record("#UPPGIFT", code, fmt.Sprint(amounts[code]))
+		}
+	}
+	w.record("#BLANKETTSLUT")
+}
+
+func (w *writer) record(label string, fields ...string) {
+	_, _ = w.bw.WriteString(strings.Join(append([]string{label}, fields...), " ") + "\r\n")
+}
+
+func (w *writer) flush() error {
+	return w.bw.Flush()
+}
@@ -0,0 +1,161 @@
+package sru
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"kastelo.dev/sie"
+)
+
+const input = `#ORGNR 556677-8899
+#FNAMN "Företaget AB"
+#ADRESS "Anna Andersson" "Box 1" "123 45 Stad" "08-123 45"
+#KONTO 1930 "Bank"
+#SRU 1930 7281
+#KONTO 2081 "Aktiekapital"
+#SRU 2081 7301
+#KONTO 3010 "Försäljning"
+#SRU 3010 7410
+#KONTO 5010 "Lokalhyra"
+#SRU 5010 7513
+#KONTO 6570 "Bankkostnader"
+#SRU 6570 7513
+#UB 0 1930 75000.40
+#UB 0 2081 -50000.00
+#RES 0 3010 -40000.00
+#RES 0 5010 12000.00
+#RES 0 6570 3000.60
+`
+
+func TestAmounts(t *testing.T) {
+	doc, err := sie.ParseWithOptions(strings.NewReader(input), sie.ParseOptions{Charset: sie.CharsetUTF8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amounts := Amounts(doc)
+	expected := map[string]int64{"7281": 75000, "7301": 50000, "7410": 40000, "7513": 15001}
+	for code, exp := range expected {
+		if amounts[code] != exp {
+			t.Errorf("code %s: got %d, expected %d", code, amounts[code], exp)
+		}
+	}
+	if len(amounts) != len(expected) {
+		t.Errorf("unexpected amounts %v", amounts)
+	}
+	if res := Result(doc); res != 24999 {
+		t.Errorf("unexpected result %d", res)
+	}
+}
+
+func TestAmountsSigned(t *testing.T) {
+	// Accounts off their normal side give negative amounts, such as a
+	// cost account with a reversal larger than the costs
+	const signed = input + `#KONTO 8310 "Ränteintäkter"
+#SRU 8310 7416
+#KONTO 4010 "Inköp"
+#SRU 4010 7511
+#RES 0 8310 150.40
+#RES 0 4010 -2000.60
+`
+	doc, err := sie.ParseWithOptions(strings.NewReader(signed), sie.ParseOptions{Charset: sie.CharsetUTF8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	amounts := Amounts(doc)
+	if amounts["7416"] != -150 || amounts["7511"] != -2001 {
+		t.Errorf("unexpected amounts %v", amounts)
+	}
+	if res := Result(doc); res != 26850 {
+		t.Errorf("unexpected result %d", res)
+	}
+}
+
+func TestResultBookedOn8999(t *testing.T) {
+	// The result booked on 8999 against 2099 before the export
+	const booked = input + `#KONTO 2099 "Årets resultat"
+#KONTO 8999 "Årets resultat"
+#UB 0 2099 -24999.40
+#RES 0 8999 24999.40
+`
+	doc, err := sie.ParseWithOptions(strings.NewReader(booked), sie.ParseOptions{Charset: sie.CharsetUTF8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := Result(doc); res != 24999 {
+		t.Errorf("unexpected result %d", res)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	doc, err := sie.ParseWithOptions(strings.NewReader(input), sie.ParseOptions{Charset: sie.CharsetUTF8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var info, forms bytes.Buffer
+	opts := Options{
+		Period:  "2024P4",
+		Program: "test",
+		Created: time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC),
+	}
+	if err := Write(&info, &forms, doc, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedInfo := "#DATABESKRIVNING_START\r\n" +
+		"#PRODUKT SRU\r\n" +
+		"#SKAPAD 20250301 123000\r\n" +
+		"#PROGRAM test\r\n" +
+		"#FILNAMN BLANKETTER.SRU\r\n" +
+		"#DATABESKRIVNING_SLUT\r\n" +
+		"#MEDIELEV_START\r\n" +
+		"#ORGNR 165566778899\r\n" +
+		"#NAMN F\xf6retaget AB\r\n" +
+		"#ADRESS Box 1\r\n" +
+		"#POSTNR 12345\r\n" +
+		"#POSTORT Stad\r\n" +
+		"#KONTAKT Anna Andersson\r\n" +
+		"#TELEFON 08-123 45\r\n" +
+		"#MEDIELEV_SLUT\r\n"
+	if info.String() != expectedInfo {
+		t.Errorf("unexpected INFO.SRU:\n%s", info.String())
+	}
+
+	expectedForms := "#BLANKETT INK2R-2024P4\r\n" +
+		"#IDENTITET 165566778899 20250301 123000\r\n" +
+		"#NAMN F\xf6retaget AB\r\n" +
+		"#UPPGIFT 7281 75000\r\n" +
+		"#UPPGIFT 7301 50000\r\n" +
+		"#UPPGIFT 7410 40000\r\n" +
+		"#UPPGIFT 7450 24999\r\n" +
+		"#UPPGIFT 7513 15001\r\n" +
+		"#BLANKETTSLUT\r\n" +
+		"#BLANKETT INK2S-2024P4\r\n" +
+		"#IDENTITET 165566778899 20250301 123000\r\n" +
+		"#NAMN F\xf6retaget AB\r\n" +
+		"#UPPGIFT 7650 24999\r\n" +
+		"#BLANKETTSLUT\r\n" +
+		"#FIL_SLUT\r\n"
+	if forms.String() != expectedForms {
+		t.Errorf("unexpected BLANKETTER.SRU:\n%s", forms.String())
+	}
+
+	for _, addr := range []string{"Stad", "123 45", "12 Stad"} {
+		doc.Company.PostalAddress = addr
+		if err := Write(&info, &forms, doc, opts); err == nil {
+			t.Errorf("%q: expected an invalid postal address error", addr)
+		}
+	}
+	doc.Company.PostalAddress = ""
+	if err := Write(&info, &forms, doc, opts); err != ErrMissingPostalAddress {
+		t.Error("expected missing postal address error, got", err)
+	}
+
+	doc.OrgNo = ""
+	if err := Write(&info, &forms, doc, opts); err != ErrMissingOrgNo {
+		t.Error("expected missing organisation number error, got", err)
+	}
+}
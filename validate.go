@@ -0,0 +1,281 @@
+package sie
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "warning":
+		*s = SeverityWarning
+	case "error":
+		*s = SeverityError
+	default:
+		return fmt.Errorf("unknown severity %q", b)
+	}
+	return nil
+}
+
+// Problem is an integrity problem found by Validate. The line number is
+// zero when the problem doesn't relate to a parsed line.
+type Problem struct {
+	Line      int      `json:"line,omitempty"`
+	Series    string   `json:"series,omitempty"`
+	EntryID   string   `json:"entryId,omitempty"`
+	AccountID int      `json:"accountId,omitempty"`
+	Severity  Severity `json:"severity"`
+	Message   string   `json:"message"`
+}
+
+func (p Problem) String() string {
+	var prefix string
+	if p.Line > 0 {
+		prefix += fmt.Sprintf("line %d: ", p.Line)
+	}
+	if p.Series != "" || p.EntryID != "" {
+		prefix += fmt.Sprintf("%s%s: ", p.Series, p.EntryID)
+	}
+	return fmt.Sprintf("%s%s: %s", prefix, p.Severity, p.Message)
+}
+
+// Validate checks the document for balance and integrity errors: entries
+// that don't balance, references to undeclared accounts and objects,
+// closing balances that don't match the opening balances and transactions,
+// entries outside the fiscal year, duplicate or missing entry numbers, and
+// accounts missing from the declared chart of accounts.
+// The problems are returned ordered by line number.
+func (d *Document) Validate() []Problem {
+	var problems []Problem
+
+	accounts := make(map[int]bool, len(d.Accounts))
+	for _, acc := range d.Accounts {
+		accounts[acc.ID] = true
+	}
+
+	for _, e := range d.Entries {
+		problems = append(problems, d.validateEntry(e, accounts)...)
+
+		if d.outsideYear(e) {
+			year, _ := d.yearDeclared(0)
+			problems = append(problems, Problem{
+				Line:     e.Line,
+				Series:   e.Type,
+				EntryID:  e.ID,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("entry dated %s is outside the fiscal year %s - %s", date(e.Date), date(year.Starts), date(year.Ends)),
+			})
+		}
+	}
+
+	problems = append(problems, d.validateBalances(d.entrySums())...)
+	problems = append(problems, d.validateSeries()...)
+	problems = append(problems, d.validatePlan()...)
+
+	slices.SortStableFunc(problems, func(a, b Problem) int {
+		return cmp.Compare(a.Line, b.Line)
+	})
+	return problems
+}
+
+func (d *Document) validateEntry(e Entry, accounts map[int]bool) []Problem {
+	var problems []Problem
+
+	var sum Decimal
+	for _, t := range e.EffectiveTransactions() {
+		sum += t.Amount
+
+		if !accounts[t.AccountID] {
+			problems = append(problems, Problem{
+				Line:      t.Line,
+				Series:    e.Type,
+				EntryID:   e.ID,
+				AccountID: t.AccountID,
+				Severity:  SeverityError,
+				Message:   fmt.Sprintf("account %d is not declared", t.AccountID),
+			})
+		}
+		for _, a := range t.Annotations {
+			if !slices.ContainsFunc(d.Annotations, a.Equals) {
+				problems = append(problems, Problem{
+					Line:      t.Line,
+					Series:    e.Type,
+					EntryID:   e.ID,
+					AccountID: t.AccountID,
+					Severity:  SeverityWarning,
+					Message:   fmt.Sprintf("object %q in dimension %d is not declared", a.Text, a.Tag),
+				})
+			}
+		}
+	}
+
+	if sum != 0 {
+		problems = append(problems, Problem{
+			Line:     e.Line,
+			Series:   e.Type,
+			EntryID:  e.ID,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("entry does not balance, transactions sum to %s", sum.FloatString(2)),
+		})
+	}
+
+	return problems
+}
+
+// validateBalances checks that the opening balance plus the transactions
+// equals the closing balance (or, for result accounts with a #RES, the
+// result) for each account. Files without any closing balances are not
+// checked.
+func (d *Document) validateBalances(sums map[int]Decimal) []Problem {
+	hasClosing := slices.ContainsFunc(d.Accounts, func(acc Account) bool {
+		return acc.OutBalance != 0 || acc.Result != 0
+	})
+	if !hasClosing {
+		return nil
+	}
+
+	var problems []Problem
+	for _, acc := range d.Accounts {
+		label, expected, actual := "closing balance", acc.InBalance+sums[acc.ID], acc.OutBalance
+		if acc.hasResult(d.AccountPlan) {
+			label, expected, actual = "result", sums[acc.ID], acc.Result
+		}
+		if expected != actual {
+			problems = append(problems, Problem{
+				Line:      acc.Line,
+				AccountID: acc.ID,
+				Severity:  SeverityError,
+				Message:   fmt.Sprintf("%s for account %d is %s, expected %s from opening balance and transactions", label, acc.ID, actual.FloatString(2), expected.FloatString(2)),
+			})
+		}
+	}
+	return problems
+}
+
+// validateSeries checks for duplicate and missing entry numbers within
+// each series. Entries without a number, or with a non-numeric one, are
+// not considered for gaps.
+func (d *Document) validateSeries() []Problem {
+	var problems []Problem
+
+	type seriesEntry struct {
+		num  int
+		line int
+	}
+	seen := make(map[string]map[string]int) // series -> ID -> line
+	numbers := make(map[string][]seriesEntry)
+	var series []string
+	for _, e := range d.Entries {
+		if e.ID == "" {
+			continue
+		}
+		if seen[e.Type] == nil {
+			seen[e.Type] = make(map[string]int)
+			series = append(series, e.Type)
+		}
+		if line, ok := seen[e.Type][e.ID]; ok {
+			msg := "duplicate entry number"
+			if line > 0 {
+				msg += fmt.Sprintf(", first seen on line %d", line)
+			}
+			problems = append(problems, Problem{
+				Line:     e.Line,
+				Series:   e.Type,
+				EntryID:  e.ID,
+				Severity: SeverityError,
+				Message:  msg,
+			})
+			continue
+		}
+		seen[e.Type][e.ID] = e.Line
+		if num, err := strconv.Atoi(e.ID); err == nil {
+			numbers[e.Type] = append(numbers[e.Type], seriesEntry{num, e.Line})
+		}
+	}
+
+	for _, s := range series {
+		nums := numbers[s]
+		slices.SortFunc(nums, func(a, b seriesEntry) int { return cmp.Compare(a.num, b.num) })
+		for i := 1; i < len(nums); i++ {
+			if gap := nums[i].num - nums[i-1].num; gap > 1 {
+				missing := strconv.Itoa(nums[i-1].num + 1)
+				if gap > 2 {
+					missing += "-" + strconv.Itoa(nums[i].num-1)
+				}
+				problems = append(problems, Problem{
+					Line:     nums[i].line,
+					Series:   s,
+					EntryID:  strconv.Itoa(nums[i].num),
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("gap in series %s, missing %s", s, missing),
+				})
+			}
+		}
+	}
+
+	return problems
+}
+
+// outsideYear returns true if the entry is dated outside the fiscal year
+// declared by #RAR.
+func (d *Document) outsideYear(e Entry) bool {
+	year, ok := d.yearDeclared(0)
+	return ok && (e.Date.Before(year.Starts) || e.Date.After(year.Ends))
+}
+
+// entrySums returns the sum of the transactions per account, for the
+// entries within the fiscal year.
+func (d *Document) entrySums() map[int]Decimal {
+	sums := make(map[int]Decimal)
+	for _, e := range d.Entries {
+		if d.outsideYear(e) {
+			continue
+		}
+		for _, t := range e.EffectiveTransactions() {
+			sums[t.AccountID] += t.Amount
+		}
+	}
+	return sums
+}
+
+// hasResult returns true if the balance of the account for the year is
+// given as a result (#RES) rather than as a closing balance. Some files
+// give the result of result accounts as #UB.
+func (a Account) hasResult(plan string) bool {
+	return a.IsResultAccount(plan) && (a.Result != 0 || a.OutBalance == 0)
+}
+
+// yearDeclared returns the fiscal year with the given index if declared by
+// #RAR, as opposed to Year which also considers the span of the entries.
+func (d *Document) yearDeclared(index int) (FiscalYear, bool) {
+	for _, y := range d.Years {
+		if y.Index == index {
+			return y, true
+		}
+	}
+	return FiscalYear{}, false
+}
@@ -0,0 +1,76 @@
+package sie
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateTestdata(t *testing.T) {
+	fd, err := os.Open("testdata/testdata.se")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	doc, err := Parse(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The objects used in the first entry are not declared
+	problems := doc.Validate()
+	if len(problems) != 2 {
+		t.Fatalf("expected two problems, got %v", problems)
+	}
+	for _, p := range problems {
+		if p.Severity != SeverityWarning || !strings.Contains(p.Message, "not declared") {
+			t.Errorf("unexpected problem %v", p)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	const input = `#RAR 0 20160101 20161231
+#KONTO 1930 "Bank"
+#KONTO 3010 "Sales"
+#OBJEKT 6 "P1" "Project"
+#IB 0 1930 100.00
+#UB 0 1930 200.00
+#RES 0 3010 -300.00
+#VER A 1 20160102 "Sale" 20160103
+{
+#TRANS 1930 {6 "P1"} 300.00
+#TRANS 3010 {6 "P2"} -300.00
+}
+#VER A 1 20160105 "Duplicate" 20160105
+{
+#TRANS 1930 {} 100.00
+#TRANS 4010 {} -100.00
+}
+#VER A 4 20170105 "Next year" 20170105
+{
+#TRANS 1930 {} 100.00
+#TRANS 3010 {} -50.00
+}
+`
+
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Problem{
+		{Line: 2, AccountID: 1930, Severity: SeverityError, Message: "closing balance for account 1930 is 200.00, expected 500.00 from opening balance and transactions"},
+		{Line: 11, Series: "A", EntryID: "1", AccountID: 3010, Severity: SeverityWarning, Message: `object "P2" in dimension 6 is not declared`},
+		{Line: 13, Series: "A", EntryID: "1", Severity: SeverityError, Message: "duplicate entry number, first seen on line 8"},
+		{Line: 16, Series: "A", EntryID: "1", AccountID: 4010, Severity: SeverityError, Message: "account 4010 is not declared"},
+		{Line: 18, Series: "A", EntryID: "4", Severity: SeverityError, Message: "entry does not balance, transactions sum to 50.00"},
+		{Line: 18, Series: "A", EntryID: "4", Severity: SeverityWarning, Message: "entry dated 20170105 is outside the fiscal year 20160101 - 20161231"},
+		{Line: 18, Series: "A", EntryID: "4", Severity: SeverityWarning, Message: "gap in series A, missing 2-3"},
+	}
+
+	problems := doc.Validate()
+	if got, exp := jsons(problems), jsons(expected); got != exp {
+		t.Errorf("unexpected problems\ngot:  %s\nwant: %s", got, exp)
+	}
+}
@@ -0,0 +1,391 @@
+package sie
+
+import (
+	"bufio"
+	"cmp"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// WriteOptions controls the behaviour of WriteWithOptions. The zero value
+// gives the same behaviour as Write.
+type WriteOptions struct {
+	// Checksum adds a #KSUMMA checksum to the file.
+	Checksum bool
+}
+
+// Write serialises doc as a SIE 4 file in the PC8 (CP437) character set.
+// Characters that cannot be represented in CP437 are replaced.
+func Write(w io.Writer, doc *Document) error {
+	return WriteWithOptions(w, doc, WriteOptions{})
+}
+
+func WriteWithOptions(w io.Writer, doc *Document, opts WriteOptions) error {
+	enc := encoding.ReplaceUnsupported(charmap.CodePage437.NewEncoder())
+	bw := bufio.NewWriter(enc.Writer(w))
+	sw := &writer{w: bw}
+	sw.document(doc, opts)
+	return bw.Flush()
+}
+
+// WriteTo writes the document to w in SIE 4 format. It implements
+// io.WriterTo.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := Write(cw, d)
+	return cw.n, err
+}
+
+type writer struct {
+	w   io.Writer
+	sum *checksum
+
+	// Unknown records not yet written
+	unknown []Record
+}
+
+func (w *writer) document(doc *Document, opts WriteOptions) {
+	programName := doc.ProgramName
+	if programName == "" {
+		programName = "kastelo.dev/sie"
+	}
+	generatedAt := doc.GeneratedAt
+	if generatedAt.IsZero() {
+		generatedAt = time.Now()
+	}
+	sieType := doc.Type
+	if sieType == "" {
+		sieType = "4"
+	}
+
+	w.record("#FLAGGA", "0")
+	if opts.Checksum {
+		w.record("#KSUMMA")
+		w.sum = newChecksum()
+	}
+	w.record("#PROGRAM", quote(programName), quote(doc.ProgramVersion))
+	w.record("#FORMAT", "PC8")
+	if doc.GeneratedBy != "" {
+		w.record("#GEN", date(generatedAt), quote(doc.GeneratedBy))
+	} else {
+		w.record("#GEN", date(generatedAt))
+	}
+	w.record("#SIETYP", field(sieType))
+	co := doc.Company
+	if co.Notes != "" {
+		w.record("#PROSA", quote(co.Notes))
+	}
+	if co.Type != "" {
+		w.record("#FTYP", field(co.Type))
+	}
+	if co.ID != "" {
+		w.record("#FNR", field(co.ID))
+	}
+	if doc.OrgNo != "" {
+		w.record("#ORGNR", field(doc.OrgNo))
+	}
+	if co.SNI != "" {
+		w.record("#BKOD", field(co.SNI))
+	}
+	if co.Contact != "" || co.Address != "" || co.PostalAddress != "" || co.Phone != "" {
+		w.record("#ADRESS", quote(co.Contact), quote(co.Address), quote(co.PostalAddress), quote(co.Phone))
+	}
+	if doc.CompanyName != "" {
+		w.record("#FNAMN", quote(doc.CompanyName))
+	}
+	// The declared fiscal years take precedence over Starts and Ends,
+	// which the parser widens to cover the dates of all entries
+	years := slices.Clone(doc.Years)
+	if len(years) == 0 && !doc.Starts.IsZero() && !doc.Ends.IsZero() {
+		years = []FiscalYear{{Index: 0, Starts: doc.Starts, Ends: doc.Ends}}
+	}
+	slices.SortStableFunc(years, func(a, b FiscalYear) int { return cmp.Compare(b.Index, a.Index) })
+	for _, y := range years {
+		w.record("#RAR", strconv.Itoa(y.Index), date(y.Starts), date(y.Ends))
+	}
+	if co.TaxYear != 0 {
+		w.record("#TAXAR", strconv.Itoa(co.TaxYear))
+	}
+	if co.BalanceDate != nil {
+		w.record("#OMFATTN", date(*co.BalanceDate))
+	}
+	if doc.AccountPlan != "" {
+		w.record("#KPTYP", field(doc.AccountPlan))
+	}
+	if co.Currency != "" {
+		w.record("#VALUTA", field(co.Currency))
+	}
+
+	// Unknown records are written after the header, the account
+	// declarations or the entries, depending on where they were in the
+	// original file
+	w.unknown = doc.Unknown
+	if len(doc.Accounts) > 0 {
+		w.unknownBefore(firstLine(doc.Accounts, func(acc Account) int { return acc.Line }))
+	}
+
+	for _, acc := range doc.Accounts {
+		w.record("#KONTO", strconv.Itoa(acc.ID), quote(acc.Description))
+		if acc.Type != "" {
+			w.record("#KTYP", strconv.Itoa(acc.ID), field(string(acc.Type)))
+		}
+		if acc.SRU != "" {
+			w.record("#SRU", strconv.Itoa(acc.ID), field(acc.SRU))
+		}
+	}
+
+	if len(doc.Entries) > 0 {
+		w.unknownBefore(firstLine(doc.Entries, func(e Entry) int { return e.Line }))
+	} else {
+		w.unknownBefore(math.MaxInt)
+	}
+
+	for _, dim := range doc.Dimensions {
+		if dim.Parent != 0 {
+			w.record("#UNDERDIM", strconv.Itoa(dim.ID), quote(dim.Name), strconv.Itoa(dim.Parent))
+		} else {
+			w.record("#DIM", strconv.Itoa(dim.ID), quote(dim.Name))
+		}
+	}
+
+	for _, ann := range doc.Annotations {
+		w.record("#OBJEKT", strconv.Itoa(ann.Tag), quote(ann.Text), quote(ann.Description))
+	}
+
+	for _, year := range balanceYears(doc) {
+		for _, acc := range doc.Accounts {
+			bal := acc.Balance(year)
+			if bal.In != 0 {
+				w.record("#IB", strconv.Itoa(year), strconv.Itoa(acc.ID), bal.In.FloatString(2))
+			}
+			if bal.Out != 0 {
+				w.record("#UB", strconv.Itoa(year), strconv.Itoa(acc.ID), bal.Out.FloatString(2))
+			}
+		}
+	}
+	for _, acc := range doc.Accounts {
+		for _, ob := range acc.ObjectBalances {
+			w.objectBalance("#OIB", acc.ID, ob.Year, ob.Annotation, ob.In, ob.InQuantity)
+			w.objectBalance("#OUB", acc.ID, ob.Year, ob.Annotation, ob.Out, ob.OutQuantity)
+		}
+	}
+	for _, year := range balanceYears(doc) {
+		for _, acc := range doc.Accounts {
+			if bal := acc.Balance(year); bal.Result != 0 {
+				w.record("#RES", strconv.Itoa(year), strconv.Itoa(acc.ID), bal.Result.FloatString(2))
+			}
+		}
+	}
+
+	for _, acc := range doc.Accounts {
+		for _, pb := range acc.Periods {
+			w.periodBalance("#PSALDO", acc.ID, pb)
+		}
+	}
+	for _, acc := range doc.Accounts {
+		for _, pb := range acc.Budgets {
+			w.periodBalance("#PBUDGET", acc.ID, pb)
+		}
+	}
+
+	for _, e := range doc.Entries {
+		w.entry(e)
+	}
+	w.unknownBefore(math.MaxInt)
+
+	if w.sum != nil {
+		sum := w.sum.Sum32()
+		w.sum = nil
+		w.record("#KSUMMA", strconv.FormatUint(uint64(sum), 10))
+	}
+}
+
+func (w *writer) objectBalance(label string, accID, year int, ann Annotation, amount Decimal, quantity Quantity) {
+	fields := []string{strconv.Itoa(year), strconv.Itoa(accID), objectList([]Annotation{ann}), amount.FloatString(2)}
+	if !quantity.IsZero() {
+		fields = append(fields, quantity.String())
+	}
+	w.record(label, fields...)
+}
+
+func (w *writer) periodBalance(label string, accID int, pb PeriodBalance) {
+	fields := []string{strconv.Itoa(pb.Year), pb.Period.Format("200601"), strconv.Itoa(accID), objectList(pb.Annotations), pb.Amount.FloatString(2)}
+	if !pb.Quantity.IsZero() {
+		fields = append(fields, pb.Quantity.String())
+	}
+	w.record(label, fields...)
+}
+
+func (w *writer) entry(e Entry) {
+	fields := []string{field(e.Type), field(e.ID), date(e.Date), quote(e.Description)}
+	if !e.Filed.IsZero() || e.Sign != "" {
+		fields = append(fields, optionalDate(e.Filed))
+	}
+	if e.Sign != "" {
+		fields = append(fields, quote(e.Sign))
+	}
+	w.record("#VER", fields...)
+	w.record("{")
+	pending := w.unknown
+	w.unknown = e.Unknown
+	for _, t := range e.Transactions {
+		w.unknownBefore(t.Line)
+		switch t.Kind {
+		case TransactionAdded:
+			// Followed by an identical #TRANS for readers that don't
+			// know #RTRANS
+			w.record("#RTRANS", transactionFields(e, t)...)
+			w.record("#TRANS", transactionFields(e, t)...)
+		case TransactionRemoved:
+			w.record("#BTRANS", transactionFields(e, t)...)
+		default:
+			w.record("#TRANS", transactionFields(e, t)...)
+		}
+	}
+	w.unknownBefore(math.MaxInt)
+	w.record("}")
+	w.unknown = pending
+}
+
+// unknownBefore writes the pending unknown records that were before the
+// given line in the original file. Records without a line number are
+// written at the first opportunity.
+func (w *writer) unknownBefore(line int) {
+	for len(w.unknown) > 0 && w.unknown[0].Line < line {
+		rec := w.unknown[0]
+		w.unknown = w.unknown[1:]
+		if rec.Raw != "" {
+			w.line(rec.Raw)
+			continue
+		}
+		fields := make([]string, len(rec.Fields))
+		for i, f := range rec.Fields {
+			if f.List {
+				fields[i] = "{" + f.Value + "}"
+			} else {
+				fields[i] = field(f.Value)
+			}
+		}
+		w.record(rec.Label, fields...)
+	}
+}
+
+// firstLine returns the lowest line number among the items.
+func firstLine[T any](items []T, line func(T) int) int {
+	first := math.MaxInt
+	for _, item := range items {
+		first = min(first, line(item))
+	}
+	return first
+}
+
+// transactionFields returns the fields of a #TRANS record. The date, text
+// and sign are left out when they are the same as for the entry, which is
+// what they default to when parsed.
+func transactionFields(e Entry, t Transaction) []string {
+	fields := []string{strconv.Itoa(t.AccountID), objectList(t.Annotations), t.Amount.FloatString(2), `""`, `""`}
+	if !t.Date.IsZero() && !t.Date.Equal(e.Date) {
+		fields[3] = date(t.Date)
+	}
+	if t.Text != e.Description {
+		fields[4] = quote(t.Text)
+	}
+	if t.Quantity.IsZero() {
+		fields = append(fields, `""`)
+	} else {
+		fields = append(fields, t.Quantity.String())
+	}
+	if t.Sign != "" && t.Sign != e.Sign {
+		fields = append(fields, quote(t.Sign))
+	}
+	for len(fields) > 3 && fields[len(fields)-1] == `""` {
+		fields = fields[:len(fields)-1]
+	}
+	return fields
+}
+
+// balanceYears returns the year indexes for which there are balances in
+// the document, current year first.
+func balanceYears(doc *Document) []int {
+	years := []int{0}
+	for _, acc := range doc.Accounts {
+		for year := range acc.Previous {
+			if !slices.Contains(years, year) {
+				years = append(years, year)
+			}
+		}
+	}
+	slices.SortFunc(years, func(a, b int) int { return cmp.Compare(b, a) })
+	return years
+}
+
+func (w *writer) record(label string, fields ...string) {
+	var b strings.Builder
+	b.WriteString(label)
+	for _, f := range fields {
+		b.WriteByte(' ')
+		b.WriteString(f)
+	}
+	w.line(b.String())
+}
+
+func (w *writer) line(s string) {
+	if w.sum != nil {
+		w.sum.addRecord(s)
+	}
+	_, _ = io.WriteString(w.w, s+"\r\n")
+}
+
+// quote returns s as a quoted SIE field, escaping quotes and backslashes.
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// field returns s as a SIE field, quoting it only when necessary.
+func field(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\"{}\\") {
+		return quote(s)
+	}
+	return s
+}
+
+func date(t time.Time) string {
+	return t.Format("20060102")
+}
+
+// optionalDate returns the date as a SIE field, or an empty field for the
+// zero time.
+func optionalDate(t time.Time) string {
+	if t.IsZero() {
+		return `""`
+	}
+	return date(t)
+}
+
+func objectList(anns []Annotation) string {
+	parts := make([]string, 0, 2*len(anns))
+	for _, a := range anns {
+		parts = append(parts, strconv.Itoa(a.Tag), quote(a.Text))
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
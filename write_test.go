@@ -0,0 +1,257 @@
+package sie
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	godiffpatch "github.com/sourcegraph/go-diff-patch"
+)
+
+func TestWriteRoundtrip(t *testing.T) {
+	fd, err := os.Open("testdata/testdata.se")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	doc, err := Parse(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if docStr, gotStr := jsons(doc), jsons(got); docStr != gotStr {
+		t.Error(godiffpatch.GeneratePatch("roundtrip", docStr, gotStr))
+	}
+}
+
+func TestWriteQuoting(t *testing.T) {
+	balanceDate := time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC)
+	doc := &Document{
+		ProgramName:    `Test "Program"`,
+		ProgramVersion: "1.0",
+		GeneratedAt:    time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC),
+		Type:           "4",
+		CompanyName:    `Räksmörgås \ AB`,
+		Company: Company{
+			Contact:       "Jakob Borg",
+			Address:       "Box 1",
+			PostalAddress: "123 45 Stad",
+			Phone:         "08-123 45",
+			SNI:           "62010",
+			Type:          "AB",
+			TaxYear:       2027,
+			BalanceDate:   &balanceDate,
+			Currency:      "SEK",
+			ID:            "F1",
+			Notes:         `Anteckning "ett"`,
+		},
+		Starts: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Ends:   time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+		Years: []FiscalYear{
+			{Index: 0, Starts: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Ends: time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)},
+			{Index: -1, Starts: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Ends: time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)},
+		},
+		Accounts: []Account{
+			{
+				ID: 1910, Type: "T", Description: "Kassa", SRU: "7281", InBalance: -50, OutBalance: 25000,
+				Previous: map[int]Balance{-1: {In: 100, Out: -50}},
+				ObjectBalances: []ObjectBalance{
+					{Year: 0, Annotation: Annotation{Tag: 6, Text: "big project"}, In: -50, Out: 100, OutQuantity: NewQuantity(2, 0)},
+				},
+			},
+			{
+				ID: 3000, Type: "I", Description: "Försäljning {inom Sverige}", Result: -25050,
+				Previous: map[int]Balance{-1: {Result: -150}},
+				Periods: []PeriodBalance{
+					{Year: 0, Period: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Amount: -25050},
+					{Year: 0, Period: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Annotations: []Annotation{{Tag: 6, Text: "big project"}}, Amount: -25050, Quantity: NewQuantity(15, 1)},
+				},
+				Budgets: []PeriodBalance{
+					{Year: 0, Period: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Amount: -20000},
+				},
+			},
+		},
+		Dimensions: []Dimension{
+			{ID: 6, Name: "Projekt"},
+			{ID: 21, Name: "Delprojekt", Parent: 6},
+		},
+		Annotations: []Annotation{{Tag: 6, Text: "big project", Description: `Projekt "Stort"`}},
+		Entries: []Entry{
+			{
+				ID:          "1",
+				Type:        "A",
+				Date:        time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC),
+				Description: `Faktura "12"`,
+				Filed:       time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC),
+				Sign:        "JB",
+				Transactions: []Transaction{
+					{AccountID: 1910, Amount: 25050, Annotations: []Annotation{{Tag: 6, Text: "big project"}}, Date: time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC), Text: "Kund \\ 12", Sign: "JB"},
+					{AccountID: 3000, Amount: -25050, Date: time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC), Text: `Faktura "12"`, Quantity: NewQuantity(3125, 3), Sign: "AB"},
+					{AccountID: 3010, Amount: -25050, Date: time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC), Text: `Faktura "12"`, Sign: "JB", Kind: TransactionRemoved},
+					{AccountID: 3000, Amount: -100, Date: time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC), Text: `Faktura "12"`, Sign: "JB", Kind: TransactionAdded},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if docStr, gotStr := jsons(doc), jsons(got); docStr != gotStr {
+		t.Error(godiffpatch.GeneratePatch("roundtrip", docStr, gotStr))
+	}
+}
+
+func TestWriteUnknownRecords(t *testing.T) {
+	const input = "#FLAGGA 0\r\n" +
+		"#PROGRAM \"Test\" \"1.0\"\r\n" +
+		"#FORMAT PC8\r\n" +
+		"#GEN 20260115\r\n" +
+		"#SIETYP 4\r\n" +
+		"#XHEAD {1 \"a b\"}  \"x\"\r\n" +
+		"#KONTO 1930 \"Bank\"\r\n" +
+		"#XACC 1930 42\r\n" +
+		"#KONTO 3010 \"Sales\"\r\n" +
+		"#VER A 1 20260110 \"Sale\"\r\n" +
+		"{\r\n" +
+		"#XROW first\r\n" +
+		"#TRANS 1930 {} 100.00 \"\" \"Sale\"\r\n" +
+		"#XROW second\r\n" +
+		"#TRANS 3010 {} -100.00 \"\" \"Sale\"\r\n" +
+		"}\r\n" +
+		"#XTAIL\r\n"
+
+	doc, err := Parse(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unknown records keep their exact form and their position
+	// relative to the accounts, entries and transactions
+	var labels []string
+	for _, line := range bytes.Split(buf.Bytes(), []byte("\r\n")) {
+		if bytes.HasPrefix(line, []byte("#X")) || bytes.HasPrefix(line, []byte("#KONTO")) || bytes.HasPrefix(line, []byte("#TRANS")) || bytes.HasPrefix(line, []byte("#VER")) {
+			labels = append(labels, string(line))
+		}
+	}
+	expected := []string{
+		`#XHEAD {1 "a b"}  "x"`,
+		`#KONTO 1930 "Bank"`,
+		`#KONTO 3010 "Sales"`,
+		`#XACC 1930 42`,
+		`#VER A 1 20260110 "Sale"`,
+		`#XROW first`,
+		`#TRANS 1930 {} 100.00`,
+		`#XROW second`,
+		`#TRANS 3010 {} -100.00`,
+		`#XTAIL`,
+	}
+	if got, exp := strings.Join(labels, "\n"), strings.Join(expected, "\n"); got != exp {
+		t.Error(godiffpatch.GeneratePatch("unknown", exp, got))
+	}
+	// Without the raw line the record is written from its fields, with
+	// the object list kept as such
+	doc.Unknown[0].Raw = ""
+	buf.Reset()
+	if err := Write(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\r\n#XHEAD {1 \"a b\"} x\r\n") {
+		t.Errorf("object list not written as such:\n%s", buf.String())
+	}
+}
+
+func TestWriteFiscalYear(t *testing.T) {
+	const input = "#SIETYP 4\r\n" +
+		"#RAR 0 20260101 20261231\r\n" +
+		"#RAR -1 20250101 20251231\r\n" +
+		"#KONTO 1930 \"Bank\"\r\n" +
+		"#KONTO 3010 \"Sales\"\r\n" +
+		"#VER A 1 20251231 \"Sale\"\r\n" +
+		"{\r\n" +
+		"#TRANS 1930 {} 100.00\r\n" +
+		"#TRANS 3010 {} -100.00\r\n" +
+		"}\r\n"
+
+	doc, err := Parse(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// The entry outside the fiscal year doesn't change the year written
+	var rars []string
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if strings.HasPrefix(line, "#RAR") {
+			rars = append(rars, line)
+		}
+	}
+	expected := []string{"#RAR 0 20260101 20261231", "#RAR -1 20250101 20251231"}
+	if got, exp := strings.Join(rars, "\n"), strings.Join(expected, "\n"); got != exp {
+		t.Error(godiffpatch.GeneratePatch("rar", exp, got))
+	}
+}
+
+func TestWriteTransactionRoundtrip(t *testing.T) {
+	// Fields equal to those of the entry are left out, and written back
+	// out the same way
+	trans := []string{
+		"#TRANS 1930 {} 100.00",
+		`#TRANS 3010 {} -80.00 20250105 "Other" 2 "AB"`,
+		"#TRANS 3010 {} -20.00",
+	}
+	input := "#SIETYP 4\r\n" +
+		"#RAR 0 20250101 20251231\r\n" +
+		"#KONTO 1930 \"Bank\"\r\n" +
+		"#KONTO 3010 \"Sales\"\r\n" +
+		"#VER A 1 20250102 \"Sale\" 20250103 \"JB\"\r\n" +
+		"{\r\n" +
+		strings.Join(trans, "\r\n") + "\r\n" +
+		"}\r\n"
+
+	doc, err := Parse(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr := doc.Entries[0].Transactions[0]; tr.Sign != "JB" {
+		t.Errorf("expected the sign of the entry, got %q", tr.Sign)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if strings.HasPrefix(line, "#TRANS") {
+			got = append(got, line)
+		}
+	}
+	if got, exp := strings.Join(got, "\n"), strings.Join(trans, "\n"); got != exp {
+		t.Error(godiffpatch.GeneratePatch("trans", exp, got))
+	}
+}